@@ -22,6 +22,25 @@ func addAdd(cmd *cobra.Command) {
 	addCmd.Flags().String("filename", "", "Target file name to use when downloading the resource")
 	addCmd.Flags().StringArray("tag", []string{}, "Resource tags")
 	addCmd.Flags().String("artifactory-cache-url", "", "Artifactory cache URL")
+	addCmd.Flags().String("comment", "", "Description of the resource stored in the lock file, e.g. why it is pinned")
+	addCmd.Flags().Bool("extract", false, "Extract the resource archive after download")
+	addCmd.Flags().Int("strip-components", 0, "Number of leading path elements removed from the extracted files")
+	addCmd.Flags().String("extract-dir", "", "Directory, relative to the download directory, to extract the resource to")
+	addCmd.Flags().Bool("manifest", false, "Record the integrity of every extracted file to detect their later modification")
+	addCmd.Flags().String("integrity", "", "Known integrity of the resource, as an SRI ('sha256-<base64>') or a hex digest ('sha256:<hex>'), to add it without downloading it")
+	addCmd.Flags().Bool("verify-now", false, "Download the resource anyway to check its known integrity")
+	addCmd.Flags().String("checksum-url", "", "URL of a checksum file (e.g. SHA256SUMS) the resource must match")
+	addCmd.MarkFlagsMutuallyExclusive("integrity", "checksum-url")
+	addCmd.Flags().String("signature-url", "", "URL of a detached signature of the resource")
+	addCmd.Flags().String("signature-format", internal.SignatureOpenPGP, fmt.Sprintf("Format of the signature (%s, %s or %s)", internal.SignatureOpenPGP, internal.SignatureMinisign, internal.SignatureSignify))
+	addCmd.Flags().String("public-key", "", "Public key, stored in the lock file, used to verify the signature (e.g. a minisign key)")
+	addCmd.Flags().String("public-key-file", "", "Public key file, stored in the lock file, used to verify the signature")
+	addCmd.Flags().String("keyring", "", "Path of an OpenPGP keyring used to verify the signature")
+	addCmd.MarkFlagsMutuallyExclusive("public-key", "public-key-file", "keyring")
+	addCmd.Flags().String("sigstore-bundle-url", "", "URL of a Sigstore bundle of the resource, verified offline")
+	addCmd.Flags().String("sigstore-trusted-root", "", "Path of the Sigstore trusted root file used to verify the bundle")
+	addCmd.Flags().String("sigstore-identity", "", "Identity (certificate subject alternative name) expected to have signed the bundle")
+	addCmd.Flags().String("sigstore-issuer", "", "OIDC issuer expected to have authenticated the identity that signed the bundle")
 	cmd.AddCommand(addCmd)
 }
 
@@ -30,6 +49,10 @@ func runAdd(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	output, err := getOutput(cmd)
+	if err != nil {
+		return err
+	}
 	// Get cache URL
 	ArtifactoryCacheURL, err := cmd.Flags().GetString("artifactory-cache-url")
 	if err != nil {
@@ -41,10 +64,11 @@ func runAdd(cmd *cobra.Command, args []string) error {
 			return fmt.Errorf("%s environment variable is not set", internal.GRABIT_ARTIFACTORY_TOKEN_ENV_VAR)
 		}
 	}
-	lock, err := internal.NewLock(lockFile, true)
+	lock, err := internal.NewLockForUpdate(lockFile, true)
 	if err != nil {
 		return err
 	}
+	defer lock.Unlock()
 	algo, err := cmd.Flags().GetString("algo")
 	if err != nil {
 		return err
@@ -57,7 +81,123 @@ func runAdd(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	err = lock.AddResource(args, algo, tags, filename, ArtifactoryCacheURL)
+	extract, err := cmd.Flags().GetBool("extract")
+	if err != nil {
+		return err
+	}
+	stripComponents, err := cmd.Flags().GetInt("strip-components")
+	if err != nil {
+		return err
+	}
+	extractDir, err := cmd.Flags().GetString("extract-dir")
+	if err != nil {
+		return err
+	}
+	manifest, err := cmd.Flags().GetBool("manifest")
+	if err != nil {
+		return err
+	}
+	if !extract && (stripComponents != 0 || extractDir != "" || manifest) {
+		return fmt.Errorf("--strip-components, --extract-dir and --manifest require --extract")
+	}
+	if stripComponents < 0 {
+		return fmt.Errorf("invalid number of components to strip: %d", stripComponents)
+	}
+	integrity, err := cmd.Flags().GetString("integrity")
+	if err != nil {
+		return err
+	}
+	verifyNow, err := cmd.Flags().GetBool("verify-now")
+	if err != nil {
+		return err
+	}
+	if integrity != "" {
+		integrity, err = internal.ParseIntegrity(integrity)
+		if err != nil {
+			return err
+		}
+		if cmd.Flags().Changed("algo") && algo != integrityAlgo(integrity) {
+			return fmt.Errorf("--algo '%s' does not match the algorithm of --integrity '%s'", algo, integrityAlgo(integrity))
+		}
+		if !verifyNow && (manifest || ArtifactoryCacheURL != "" || cmd.Flags().Changed("signature-url") || cmd.Flags().Changed("sigstore-bundle-url")) {
+			return fmt.Errorf("--manifest, --artifactory-cache-url, --signature-url and --sigstore-bundle-url need the resource content: use --verify-now with --integrity")
+		}
+	} else if verifyNow {
+		return fmt.Errorf("--verify-now requires --integrity")
+	}
+	checksumUrl, err := cmd.Flags().GetString("checksum-url")
+	if err != nil {
+		return err
+	}
+	signatureUrl, err := cmd.Flags().GetString("signature-url")
+	if err != nil {
+		return err
+	}
+	publicKeyFile, err := cmd.Flags().GetString("public-key-file")
+	if err != nil {
+		return err
+	}
+	keyring, err := cmd.Flags().GetString("keyring")
+	if err != nil {
+		return err
+	}
+	signatureFormat := ""
+	if cmd.Flags().Changed("signature-format") {
+		signatureFormat, err = cmd.Flags().GetString("signature-format")
+		if err != nil {
+			return err
+		}
+	}
+	publicKey, err := cmd.Flags().GetString("public-key")
+	if err != nil {
+		return err
+	}
+	if publicKeyFile != "" {
+		content, err := os.ReadFile(publicKeyFile)
+		if err != nil {
+			return fmt.Errorf("cannot read public key: %w", err)
+		}
+		publicKey = string(content)
+	}
+	sigstoreBundleUrl, err := cmd.Flags().GetString("sigstore-bundle-url")
+	if err != nil {
+		return err
+	}
+	sigstoreTrustedRoot, err := cmd.Flags().GetString("sigstore-trusted-root")
+	if err != nil {
+		return err
+	}
+	sigstoreIdentity, err := cmd.Flags().GetString("sigstore-identity")
+	if err != nil {
+		return err
+	}
+	sigstoreIssuer, err := cmd.Flags().GetString("sigstore-issuer")
+	if err != nil {
+		return err
+	}
+	comment, err := cmd.Flags().GetString("comment")
+	if err != nil {
+		return err
+	}
+	template := internal.Resource{
+		Urls:                args,
+		Tags:                tags,
+		Filename:            filename,
+		ArtifactoryCacheURL: ArtifactoryCacheURL,
+		Comment:             comment,
+		Extract:             extract,
+		StripComponents:     stripComponents,
+		ExtractDir:          extractDir,
+		SignatureUrl:        signatureUrl,
+		SignatureFormat:     signatureFormat,
+		PublicKey:           publicKey,
+		Keyring:             keyring,
+		SigstoreBundleUrl:   sigstoreBundleUrl,
+		SigstoreTrustedRoot: sigstoreTrustedRoot,
+		SigstoreIdentity:    sigstoreIdentity,
+		SigstoreIssuer:      sigstoreIssuer,
+	}
+	resource, err := lock.Add(template, internal.AddOptions{Algo: algo, Manifest: manifest, Integrity: integrity, VerifyNow: verifyNow, ChecksumUrl: checksumUrl})
 	if err != nil {
 		return err
 	}
@@ -65,5 +205,8 @@ func runAdd(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	if output == OUTPUT_JSON {
+		return printJSON(cmd, newResourceJSON(*resource))
+	}
 	return nil
 }
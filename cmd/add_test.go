@@ -1,12 +1,18 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/cisco-open/grabit/internal"
 	"github.com/cisco-open/grabit/test"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRunAdd(t *testing.T) {
@@ -26,3 +32,269 @@ func TestRunAddWithArtifactoryCache(t *testing.T) {
 	err := cmd.Execute()
 	assert.Nil(t, err)
 }
+
+func TestRunAddWithExtract(t *testing.T) {
+	content := test.Archive(t, "tar.gz", []test.ArchiveEntry{{Name: "pkg/README", Content: "readme"}})
+	port := test.TestHttpHandler(content, t)
+	lockFile := test.TmpFile(t, "")
+	cmd := NewRootCmd()
+	cmd.SetArgs([]string{"-f", lockFile, "add", fmt.Sprintf("http://localhost:%d/pkg.tar.gz", port), "--extract", "--strip-components", "1", "--extract-dir", "pkg"})
+	err := cmd.Execute()
+	assert.Nil(t, err)
+	lockContent, err := os.ReadFile(lockFile)
+	assert.Nil(t, err)
+	assert.Contains(t, string(lockContent), "Extract = true")
+	assert.Contains(t, string(lockContent), "StripComponents = 1")
+	assert.Contains(t, string(lockContent), "ExtractDir = 'pkg'")
+
+	dir := test.TmpDir(t)
+	cmd = NewRootCmd()
+	cmd.SetArgs([]string{"-f", lockFile, "download", "--dir", dir, "--no-cache"})
+	err = cmd.Execute()
+	assert.Nil(t, err)
+	test.AssertFileContains(t, filepath.Join(dir, "pkg", "README"), "readme")
+}
+
+func TestRunAddWithExtractNotArchive(t *testing.T) {
+	port := test.TestHttpHandler("abcdef", t)
+	cmd := NewRootCmd()
+	cmd.SetArgs([]string{"-f", test.TmpFile(t, ""), "add", fmt.Sprintf("http://localhost:%d/test.html", port), "--extract"})
+	err := cmd.Execute()
+	assert.ErrorContains(t, err, "is not a supported archive")
+}
+
+func TestRunAddStripComponentsWithoutExtract(t *testing.T) {
+	cmd := NewRootCmd()
+	cmd.SetArgs([]string{"-f", test.TmpFile(t, ""), "add", "http://localhost:123456/test.html", "--strip-components", "1"})
+	err := cmd.Execute()
+	assert.ErrorContains(t, err, "require --extract")
+}
+
+func TestRunAddWithIntegrity(t *testing.T) {
+	lockFile := test.TmpFile(t, "")
+	cmd := NewRootCmd()
+	// Nothing listens on this port: the resource must not be downloaded.
+	cmd.SetArgs([]string{"-f", lockFile, "add", "http://localhost:123456/test.html", "--integrity", "sha256:bef57ec7f53a6d40beb640a780a639c83bc29ac8a9816f1fc6c5c6dcd93c4721"})
+	err := cmd.Execute()
+	assert.Nil(t, err)
+	test.AssertFileContains(t, lockFile, fmt.Sprintf("[[Resource]]\nUrls = ['http://localhost:123456/test.html']\nIntegrity = '%s'\n", test.GetSha256Integrity("abcdef")))
+}
+
+func TestRunAddWithComment(t *testing.T) {
+	lockFile := test.TmpFile(t, `# Assets of the build.
+[[Resource]]
+  Urls = ['http://localhost:123456/test.html'] # primary mirror
+  Integrity = 'sha256-asdasdasd'
+`)
+	cmd := NewRootCmd()
+	cmd.SetArgs([]string{"-f", lockFile, "add", "http://localhost:123456/test2.html", "--integrity", test.GetSha256Integrity("abcdef"), "--comment", "Pinned until 2.0"})
+	err := cmd.Execute()
+	assert.Nil(t, err)
+	test.AssertFileContains(t, lockFile, fmt.Sprintf(`# Assets of the build.
+[[Resource]]
+  Urls = ['http://localhost:123456/test.html'] # primary mirror
+  Integrity = 'sha256-asdasdasd'
+
+[[Resource]]
+Urls = ['http://localhost:123456/test2.html']
+Integrity = '%s'
+Comment = 'Pinned until 2.0'
+`, test.GetSha256Integrity("abcdef")))
+}
+
+func TestRunAddWithIntegrityVerifyNow(t *testing.T) {
+	port := test.TestHttpHandler("abcdef", t)
+	cmd := NewRootCmd()
+	cmd.SetArgs([]string{"-f", test.TmpFile(t, ""), "add", fmt.Sprintf("http://localhost:%d/test.html", port), "--integrity", test.GetSha256Integrity("abcdef"), "--verify-now"})
+	err := cmd.Execute()
+	assert.Nil(t, err)
+
+	cmd = NewRootCmd()
+	cmd.SetArgs([]string{"-f", test.TmpFile(t, ""), "add", fmt.Sprintf("http://localhost:%d/test.html", port), "--integrity", test.GetSha256Integrity("other"), "--verify-now"})
+	err = cmd.Execute()
+	assert.ErrorContains(t, err, "integrity mismatch")
+}
+
+func TestRunAddWithIntegrityInvalidFlags(t *testing.T) {
+	integrity := test.GetSha256Integrity("abcdef")
+	tests := []struct {
+		args          []string
+		errorContains string
+	}{
+		{[]string{"--integrity", "sha256:abc"}, "invalid hex digest"},
+		{[]string{"--integrity", integrity, "--algo", "sha512"}, "does not match the algorithm"},
+		{[]string{"--integrity", integrity, "--extract", "--manifest"}, "use --verify-now"},
+		{[]string{"--integrity", integrity, "--sigstore-bundle-url", "http://localhost:123456/test.html.sigstore.json"}, "use --verify-now"},
+		{[]string{"--verify-now"}, "--verify-now requires --integrity"},
+	}
+	for _, data := range tests {
+		cmd := NewRootCmd()
+		cmd.SetArgs(append([]string{"-f", test.TmpFile(t, ""), "add", "http://localhost:123456/test.html"}, data.args...))
+		err := cmd.Execute()
+		assert.ErrorContains(t, err, data.errorContains)
+	}
+}
+
+func TestRunAddWithChecksumUrl(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		content := "abcdef"
+		if r.URL.Path == "/test.tar.gz.sha256" {
+			content = "bef57ec7f53a6d40beb640a780a639c83bc29ac8a9816f1fc6c5c6dcd93c4721\n"
+		}
+		_, err := w.Write([]byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	port, server := test.HttpHandler(handler)
+	t.Cleanup(server.Close)
+	lockFile := test.TmpFile(t, "")
+	cmd := NewRootCmd()
+	cmd.SetArgs([]string{"-f", lockFile, "add", fmt.Sprintf("http://localhost:%d/test.tar.gz", port), "--checksum-url", fmt.Sprintf("http://localhost:%d/test.tar.gz.sha256", port)})
+	err := cmd.Execute()
+	assert.Nil(t, err)
+	test.AssertFileContains(t, lockFile, fmt.Sprintf("[[Resource]]\nUrls = ['http://localhost:%d/test.tar.gz']\nIntegrity = '%s'\n", port, test.GetSha256Integrity("abcdef")))
+
+	cmd = NewRootCmd()
+	cmd.SetArgs([]string{"-f", test.TmpFile(t, ""), "add", fmt.Sprintf("http://localhost:%d/test.tar.gz", port), "--checksum-url", fmt.Sprintf("http://localhost:%d/test.tar.gz.sha256", port), "--integrity", test.GetSha256Integrity("abcdef")})
+	err = cmd.Execute()
+	assert.ErrorContains(t, err, "none of the others can be")
+}
+
+func TestRunAddWithSignature(t *testing.T) {
+	content := "abcdef"
+	signer, publicKey := test.NewOpenPGPKey(t)
+	signature := test.OpenPGPSign(t, signer, content)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		body := content
+		if r.URL.Path == "/test.html.asc" {
+			body = signature
+		}
+		_, err := w.Write([]byte(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	port, server := test.HttpHandler(handler)
+	t.Cleanup(server.Close)
+	lockFile := test.TmpFile(t, "")
+	cmd := NewRootCmd()
+	cmd.SetArgs([]string{"-f", lockFile, "add", fmt.Sprintf("http://localhost:%d/test.html", port), "--signature-url", fmt.Sprintf("http://localhost:%d/test.html.asc", port), "--public-key-file", test.TmpFile(t, publicKey)})
+	err := cmd.Execute()
+	assert.Nil(t, err)
+	lockContent, err := os.ReadFile(lockFile)
+	assert.Nil(t, err)
+	assert.Contains(t, string(lockContent), "PublicKey = \"\"\"\n-----BEGIN PGP PUBLIC KEY BLOCK-----")
+
+	_, otherPublicKey := test.NewOpenPGPKey(t)
+	cmd = NewRootCmd()
+	cmd.SetArgs([]string{"-f", test.TmpFile(t, ""), "add", fmt.Sprintf("http://localhost:%d/test.html", port), "--signature-url", fmt.Sprintf("http://localhost:%d/test.html.asc", port), "--keyring", test.TmpFile(t, otherPublicKey)})
+	err = cmd.Execute()
+	assert.ErrorContains(t, err, "invalid signature")
+}
+
+func TestRunAddWithMinisignSignature(t *testing.T) {
+	content := "abcdef"
+	signer, publicKey := test.NewMinisignKey(t)
+	signature := test.MinisignSign(t, signer, content, "file:test.html")
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		body := content
+		if r.URL.Path == "/test.html.minisig" {
+			body = signature
+		}
+		_, err := w.Write([]byte(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	port, server := test.HttpHandler(handler)
+	t.Cleanup(server.Close)
+	lockFile := test.TmpFile(t, "")
+	cmd := NewRootCmd()
+	cmd.SetArgs([]string{"-f", lockFile, "add", fmt.Sprintf("http://localhost:%d/test.html", port), "--signature-url", fmt.Sprintf("http://localhost:%d/test.html.minisig", port), "--signature-format", "minisign", "--public-key", publicKey})
+	err := cmd.Execute()
+	assert.Nil(t, err)
+	lockContent, err := os.ReadFile(lockFile)
+	assert.Nil(t, err)
+	assert.Contains(t, string(lockContent), "SignatureFormat = 'minisign'")
+
+	_, otherPublicKey := test.NewMinisignKey(t)
+	cmd = NewRootCmd()
+	cmd.SetArgs([]string{"-f", test.TmpFile(t, ""), "add", fmt.Sprintf("http://localhost:%d/test.html", port), "--signature-url", fmt.Sprintf("http://localhost:%d/test.html.minisig", port), "--signature-format", "minisign", "--public-key", otherPublicKey})
+	err = cmd.Execute()
+	assert.ErrorContains(t, err, "invalid signature")
+}
+
+func TestRunAddWithSigstoreBundle(t *testing.T) {
+	content := "abcdef"
+	identity := "https://github.com/example/tool/.github/workflows/release.yml@refs/tags/v1.0"
+	issuer := "https://token.actions.githubusercontent.com"
+	sigstore, trustedRoot := test.NewSigstore(t)
+	bundle := test.SigstoreSign(t, sigstore, identity, issuer, content)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		body := content
+		if r.URL.Path == "/test.html.sigstore.json" {
+			body = bundle
+		}
+		_, err := w.Write([]byte(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	port, server := test.HttpHandler(handler)
+	t.Cleanup(server.Close)
+	lockFile := test.TmpFile(t, "")
+	cmd := NewRootCmd()
+	cmd.SetArgs([]string{"-f", lockFile, "add", fmt.Sprintf("http://localhost:%d/test.html", port), "--sigstore-bundle-url", fmt.Sprintf("http://localhost:%d/test.html.sigstore.json", port), "--sigstore-trusted-root", trustedRoot, "--sigstore-identity", identity, "--sigstore-issuer", issuer})
+	err := cmd.Execute()
+	assert.Nil(t, err)
+	lockContent, err := os.ReadFile(lockFile)
+	assert.Nil(t, err)
+	assert.Contains(t, string(lockContent), fmt.Sprintf("SigstoreIdentity = '%s'", identity))
+
+	cmd = NewRootCmd()
+	cmd.SetArgs([]string{"-f", test.TmpFile(t, ""), "add", fmt.Sprintf("http://localhost:%d/test.html", port), "--sigstore-bundle-url", fmt.Sprintf("http://localhost:%d/test.html.sigstore.json", port), "--sigstore-trusted-root", trustedRoot, "--sigstore-identity", identity, "--sigstore-issuer", "https://accounts.google.com"})
+	err = cmd.Execute()
+	assert.ErrorContains(t, err, "invalid Sigstore bundle")
+}
+
+func TestRunAddJSON(t *testing.T) {
+	port := test.TestHttpHandler("abcdef", t)
+	cmd := NewRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"-f", test.TmpFile(t, ""), "-o", "json", "add", fmt.Sprintf("http://localhost:%d/test.html", port), "--tag", "tag"})
+	err := cmd.Execute()
+	assert.Nil(t, err)
+	var result map[string]any
+	err = json.Unmarshal(buf.Bytes(), &result)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]any{
+		"urls":      []any{fmt.Sprintf("http://localhost:%d/test.html", port)},
+		"integrity": test.GetSha256Integrity("abcdef"),
+		"tags":      []any{"tag"},
+	}, result)
+}
+
+func TestRunAddJSONError(t *testing.T) {
+	url := "http://localhost:123456/test.html"
+	testfilepath := test.TmpFile(t, fmt.Sprintf(`
+	[[Resource]]
+	Urls = ['%s']
+	Integrity = 'sha256-ZigwUkZ6+qxBgLt5/rc/OPNfWKq3sEuFXdGqPVkrqm0='
+`, url))
+	cmd := NewRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"-f", testfilepath, "-o", "json", "add", url})
+	executed, err := cmd.ExecuteC()
+	require.NotNil(t, err)
+	printJSONError(executed, err)
+	var result map[string]any
+	err = json.Unmarshal(buf.Bytes(), &result)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]any{
+		"error": fmt.Sprintf("resource '%s' is already present", url),
+		"url":   url,
+	}, result)
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func addCache(cmd *cobra.Command) {
+	cacheCmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manage the local download cache",
+	}
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the cached resources",
+		Args:  cobra.NoArgs,
+		RunE:  runCacheList,
+	}
+	verifyCmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Verify the integrity of the cached resources",
+		Args:  cobra.NoArgs,
+		RunE:  runCacheVerify,
+	}
+	pruneCmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove the least recently used cached resources",
+		Args:  cobra.NoArgs,
+		RunE:  runCachePrune,
+	}
+	pruneCmd.Flags().Duration("max-age", 0, "Remove the resources not used for longer than this duration (e.g. '720h')")
+	pruneCmd.Flags().String("max-size", "", "Remove the least recently used resources until the cache is no larger than this size (e.g. '10GB')")
+	clearCmd := &cobra.Command{
+		Use:   "clear",
+		Short: "Remove all the cached resources",
+		Args:  cobra.NoArgs,
+		RunE:  runCacheClear,
+	}
+	cacheCmd.AddCommand(listCmd, verifyCmd, pruneCmd, clearCmd)
+	cmd.AddCommand(cacheCmd)
+}
+
+func runCacheList(cmd *cobra.Command, args []string) error {
+	cache, err := getCache(cmd)
+	if err != nil {
+		return err
+	}
+	output, err := getOutput(cmd)
+	if err != nil {
+		return err
+	}
+	entries, err := cache.List()
+	if err != nil {
+		return err
+	}
+	if output == OUTPUT_JSON {
+		listed := []cacheEntryJSON{}
+		for _, e := range entries {
+			listed = append(listed, newCacheEntryJSON(e))
+		}
+		return printJSON(cmd, listed)
+	}
+	out := cmd.OutOrStdout()
+	total := int64(0)
+	for _, e := range entries {
+		total += e.Size
+		fmt.Fprintf(out, "%-10s %s  %s\n", humanize.Bytes(uint64(e.Size)), e.ModTime.Format(time.DateTime), e.Integrity)
+	}
+	fmt.Fprintf(out, "%d resources, %s in %s\n", len(entries), humanize.Bytes(uint64(total)), cache.Dir())
+	return nil
+}
+
+func runCacheVerify(cmd *cobra.Command, args []string) error {
+	cache, err := getCache(cmd)
+	if err != nil {
+		return err
+	}
+	output, err := getOutput(cmd)
+	if err != nil {
+		return err
+	}
+	corrupted, err := cache.Verify()
+	if output == OUTPUT_JSON {
+		listed := []cacheEntryJSON{}
+		for _, e := range corrupted {
+			listed = append(listed, newCacheEntryJSON(e))
+		}
+		jsonErr := printJSON(cmd, listed)
+		if jsonErr != nil {
+			return jsonErr
+		}
+	} else {
+		for _, e := range corrupted {
+			fmt.Fprintf(cmd.OutOrStdout(), "%-10s %s\n", "CORRUPTED", e.Path)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%d corrupted cache entries: %w", len(corrupted), err)
+	}
+	return nil
+}
+
+func runCachePrune(cmd *cobra.Command, args []string) error {
+	maxAge, err := cmd.Flags().GetDuration("max-age")
+	if err != nil {
+		return err
+	}
+	maxSizeStr, err := cmd.Flags().GetString("max-size")
+	if err != nil {
+		return err
+	}
+	maxSize := uint64(0)
+	if maxSizeStr != "" {
+		maxSize, err = humanize.ParseBytes(maxSizeStr)
+		if err != nil {
+			return fmt.Errorf("invalid size '%s': %w", maxSizeStr, err)
+		}
+	}
+	if maxAge == 0 && maxSize == 0 {
+		return fmt.Errorf("at least one of --max-age or --max-size is required")
+	}
+	cache, err := getCache(cmd)
+	if err != nil {
+		return err
+	}
+	removed, err := cache.Prune(maxAge, int64(maxSize))
+	freed := int64(0)
+	for _, e := range removed {
+		freed += e.Size
+	}
+	log.Info().Msgf("Removed %d cached resources (%s)", len(removed), humanize.Bytes(uint64(freed)))
+	return err
+}
+
+func runCacheClear(cmd *cobra.Command, args []string) error {
+	cache, err := getCache(cmd)
+	if err != nil {
+		return err
+	}
+	return cache.Clear()
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/cisco-open/grabit/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunCache(t *testing.T) {
+	content := `abcdef`
+	contentIntegrity := test.GetSha256Integrity(content)
+	port := test.TestHttpHandler(content, t)
+	testfilepath := test.TmpFile(t, fmt.Sprintf(`
+	[[Resource]]
+	Urls = ['http://localhost:%d/test.html']
+	Integrity = '%s'
+`, port, contentIntegrity))
+	cacheDir := test.TmpDir(t)
+	cmd := NewRootCmd()
+	cmd.SetArgs([]string{"-f", testfilepath, "--cache-dir", cacheDir, "download", "--dir", test.TmpDir(t)})
+	err := cmd.Execute()
+	assert.Nil(t, err)
+
+	cmd = NewRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"--cache-dir", cacheDir, "cache", "list"})
+	err = cmd.Execute()
+	assert.Nil(t, err)
+	assert.Contains(t, buf.String(), contentIntegrity)
+	assert.Contains(t, buf.String(), "1 resources")
+
+	cmd = NewRootCmd()
+	cmd.SetArgs([]string{"--cache-dir", cacheDir, "cache", "verify"})
+	err = cmd.Execute()
+	assert.Nil(t, err)
+
+	cmd = NewRootCmd()
+	cmd.SetArgs([]string{"--cache-dir", cacheDir, "cache", "prune"})
+	err = cmd.Execute()
+	assert.NotNil(t, err)
+
+	cmd = NewRootCmd()
+	cmd.SetArgs([]string{"--cache-dir", cacheDir, "cache", "prune", "--max-size", "1B"})
+	err = cmd.Execute()
+	assert.Nil(t, err)
+
+	cmd = NewRootCmd()
+	cmd.SetArgs([]string{"--cache-dir", cacheDir, "cache", "clear"})
+	err = cmd.Execute()
+	assert.Nil(t, err)
+
+	cmd = NewRootCmd()
+	buf = new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"--cache-dir", cacheDir, "cache", "list"})
+	err = cmd.Execute()
+	assert.Nil(t, err)
+	assert.Contains(t, buf.String(), "0 resources")
+}
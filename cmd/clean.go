@@ -0,0 +1,90 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cisco-open/grabit/internal"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func addClean(cmd *cobra.Command) {
+	cleanCmd := &cobra.Command{
+		Use:   "clean",
+		Short: "Remove the files of a download directory that are not described by the lock file",
+		Long:  "Remove the files of a download directory that are not described by the lock file, including orphaned partial downloads. Only the resources selected with --tag and --notag are kept. The directory of the lock file and directories under version control are only cleaned with --force.",
+		Args:  cobra.NoArgs,
+		RunE:  runClean,
+	}
+	cleanCmd.Flags().String("dir", "", "Directory where the files were downloaded")
+	cleanCmd.Flags().StringArray("tag", []string{}, "Only keep the resources with the given tag")
+	cleanCmd.Flags().StringArray("notag", []string{}, "Only keep the resources without the given tag")
+	cleanCmd.Flags().Bool("dry-run", false, "Only report the files that would be removed")
+	cleanCmd.Flags().Bool("force", false, "Clean the directory even if it holds the lock file or is under version control")
+	cleanCmd.MarkFlagRequired("dir")
+	cmd.AddCommand(cleanCmd)
+}
+
+func runClean(cmd *cobra.Command, args []string) error {
+	lockFile, err := cmd.Flags().GetString("lock-file")
+	if err != nil {
+		return err
+	}
+	lock, err := internal.NewLock(lockFile, false)
+	if err != nil {
+		return err
+	}
+	dir, err := cmd.Flags().GetString("dir")
+	if err != nil {
+		return err
+	}
+	tags, err := cmd.Flags().GetStringArray("tag")
+	if err != nil {
+		return err
+	}
+	notags, err := cmd.Flags().GetStringArray("notag")
+	if err != nil {
+		return err
+	}
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return err
+	}
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		return err
+	}
+	output, err := getOutput(cmd)
+	if err != nil {
+		return err
+	}
+	removed, err := lock.Clean(dir, internal.NewTagFilter(tags, notags), internal.CleanOptions{DryRun: dryRun, Force: force})
+	if errors.Is(err, internal.ErrNotDownloadDir) {
+		return fmt.Errorf("%w: use --force to remove all its files that are not described by the lock file", err)
+	}
+	if removed == nil {
+		return err
+	}
+	if output == OUTPUT_JSON {
+		jsonErr := printJSON(cmd, removed)
+		if jsonErr != nil {
+			return jsonErr
+		}
+	} else {
+		status := "REMOVED"
+		if dryRun {
+			status = "STALE"
+		}
+		for _, p := range removed {
+			fmt.Fprintf(cmd.OutOrStdout(), "%-10s %s\n", status, p)
+		}
+	}
+	if dryRun {
+		log.Info().Msgf("Dry run: %d file(s) would be removed", len(removed))
+	}
+	return err
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cisco-open/grabit/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunClean(t *testing.T) {
+	content := `abcdef`
+	port := test.TestHttpHandler(content, t)
+	testfilepath := test.TmpFile(t, fmt.Sprintf(`
+	[[Resource]]
+	Urls = ['http://localhost:%d/test.html']
+	Integrity = '%s'
+`, port, test.GetSha256Integrity(content)))
+	outputDir := test.TmpDir(t)
+	cmd := NewRootCmd()
+	cmd.SetArgs([]string{"-f", testfilepath, "download", "--dir", outputDir})
+	err := cmd.Execute()
+	require.Nil(t, err)
+	stale := filepath.Join(outputDir, "stale.html")
+	require.Nil(t, os.WriteFile(stale, []byte(content), 0644))
+
+	cmd = NewRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"-f", testfilepath, "clean", "--dir", outputDir, "--dry-run"})
+	err = cmd.Execute()
+	assert.Nil(t, err)
+	assert.Contains(t, buf.String(), "STALE")
+	assert.Contains(t, buf.String(), stale)
+	assert.FileExists(t, stale)
+
+	cmd = NewRootCmd()
+	buf = new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"-f", testfilepath, "clean", "--dir", outputDir})
+	err = cmd.Execute()
+	assert.Nil(t, err)
+	assert.Contains(t, buf.String(), "REMOVED")
+	assert.NoFileExists(t, stale)
+	assert.FileExists(t, filepath.Join(outputDir, "test.html"))
+}
+
+func TestRunCleanRequiresDir(t *testing.T) {
+	testfilepath := test.TmpFile(t, "")
+	cmd := NewRootCmd()
+	cmd.SetArgs([]string{"-f", testfilepath, "clean"})
+	err := cmd.Execute()
+	assert.ErrorContains(t, err, "dir")
+}
+
+func TestRunCleanLockFileDir(t *testing.T) {
+	dir := test.TmpDir(t)
+	testfilepath := filepath.Join(dir, "grabit.lock")
+	require.Nil(t, os.WriteFile(testfilepath, []byte(""), 0644))
+	source := filepath.Join(dir, "main.go")
+	require.Nil(t, os.WriteFile(source, []byte("package main"), 0644))
+	cmd := NewRootCmd()
+	cmd.SetArgs([]string{"-f", testfilepath, "clean", "--dir", dir})
+	err := cmd.Execute()
+	assert.ErrorContains(t, err, "use --force")
+	assert.FileExists(t, source)
+
+	cmd = NewRootCmd()
+	cmd.SetArgs([]string{"-f", testfilepath, "clean", "--dir", dir, "--force"})
+	err = cmd.Execute()
+	assert.Nil(t, err)
+	assert.NoFileExists(t, source)
+	assert.FileExists(t, testfilepath)
+}
@@ -4,17 +4,25 @@
 package cmd
 
 import (
+	"fmt"
+
 	"github.com/cisco-open/grabit/internal"
 	"github.com/spf13/cobra"
 )
 
 func addDelete(cmd *cobra.Command) {
 	var delCmd = &cobra.Command{
-		Use:   "delete",
+		Use:   "delete [url...]",
 		Short: "Delete existing resources",
-		Args:  cobra.MinimumNArgs(1),
+		Long:  "Delete the resources with the given urls, tags or integrities from the lock file, and optionally their downloaded files.",
 		RunE:  runDel,
 	}
+	delCmd.Flags().StringArray("tag", []string{}, "Delete the resources with the given tag")
+	delCmd.Flags().StringArray("notag", []string{}, "Only delete the resources without the given tag")
+	delCmd.Flags().StringArray("integrity", []string{}, "Delete the resource with the given integrity")
+	delCmd.Flags().String("dir", "", "Directory where the files were downloaded")
+	delCmd.Flags().Bool("remove-files", false, "Also remove the downloaded files and extracted content of the resources from --dir")
+	delCmd.MarkFlagsRequiredTogether("dir", "remove-files")
 	cmd.AddCommand(delCmd)
 }
 
@@ -23,19 +31,67 @@ func runDel(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	lock, err := internal.NewLock(lockFile, false)
+	output, err := getOutput(cmd)
+	if err != nil {
+		return err
+	}
+	tags, err := cmd.Flags().GetStringArray("tag")
 	if err != nil {
 		return err
 	}
-	for _, r := range args {
-		err = lock.DeleteResource(r)
+	notags, err := cmd.Flags().GetStringArray("notag")
+	if err != nil {
+		return err
+	}
+	integrities, err := cmd.Flags().GetStringArray("integrity")
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 && len(tags) == 0 && len(integrities) == 0 {
+		return fmt.Errorf("nothing to delete: give urls, --tag or --integrity")
+	}
+	for i, integrity := range integrities {
+		integrities[i], err = internal.ParseIntegrity(integrity)
 		if err != nil {
 			return err
 		}
 	}
+	dir, err := cmd.Flags().GetString("dir")
+	if err != nil {
+		return err
+	}
+	removeFiles, err := cmd.Flags().GetBool("remove-files")
+	if err != nil {
+		return err
+	}
+	lock, err := internal.NewLockForUpdate(lockFile, false)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	filter := internal.ResourceFilter{Tags: tags, NoTags: notags, Urls: args, Integrities: integrities}
+	if removeFiles {
+		_, err := lock.RemoveFiles(dir, filter)
+		if err != nil {
+			// Keep the resources in the lock file so that the removal can
+			// be attempted again.
+			return err
+		}
+	}
+	resources, err := lock.Delete(filter)
+	if err != nil {
+		return err
+	}
 	err = lock.Save()
 	if err != nil {
 		return err
 	}
+	if output == OUTPUT_JSON {
+		deleted := []resourceJSON{}
+		for _, r := range resources {
+			deleted = append(deleted, newResourceJSON(r))
+		}
+		return printJSON(cmd, deleted)
+	}
 	return nil
 }
@@ -1,10 +1,18 @@
 package cmd
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
 	"testing"
 
+	"github.com/cisco-open/grabit/internal"
 	"github.com/cisco-open/grabit/test"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRunDelete(t *testing.T) {
@@ -20,3 +28,88 @@ func TestRunDelete(t *testing.T) {
 	err := cmd.Execute()
 	assert.Nil(t, err)
 }
+
+func TestRunDeleteJSON(t *testing.T) {
+	testfilepath := test.TmpFile(t, `
+	[[Resource]]
+	Urls = ['http://localhost:123456/test.html']
+	Integrity = 'sha256-asdasdasd'
+
+	[[Resource]]
+	Urls = ['http://localhost:123456/test2.html']
+	Integrity = 'sha256-asdasdasd'
+`)
+	cmd := NewRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"-f", testfilepath, "-o", "json", "delete", "http://localhost:123456/test.html"})
+	err := cmd.Execute()
+	assert.Nil(t, err)
+	var results []map[string]any
+	err = json.Unmarshal(buf.Bytes(), &results)
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]any{{
+		"urls":      []any{"http://localhost:123456/test.html"},
+		"integrity": "sha256-asdasdasd",
+	}}, results)
+}
+
+func TestRunDeleteRemoveFiles(t *testing.T) {
+	content := `abcdef`
+	port := test.TestHttpHandler(content, t)
+	testfilepath := test.TmpFile(t, fmt.Sprintf(`
+	[[Resource]]
+	Urls = ['http://localhost:%d/test.html']
+	Integrity = '%s'
+	Tags = ['tag']
+
+	[[Resource]]
+	Urls = ['http://localhost:%d/test2.html']
+	Integrity = '%s'
+	Filename = 'test2.html'
+`, port, test.GetSha256Integrity(content), port, test.GetSha256Integrity(content)))
+	outputDir := test.TmpDir(t)
+	cmd := NewRootCmd()
+	cmd.SetArgs([]string{"-f", testfilepath, "download", "--dir", outputDir})
+	err := cmd.Execute()
+	require.Nil(t, err)
+
+	cmd = NewRootCmd()
+	cmd.SetArgs([]string{"-f", testfilepath, "delete", "--tag", "tag", "--dir", outputDir, "--remove-files"})
+	err = cmd.Execute()
+	assert.Nil(t, err)
+	assert.NoFileExists(t, filepath.Join(outputDir, "test.html"))
+	assert.FileExists(t, filepath.Join(outputDir, "test2.html"))
+	lock, err := internal.NewLock(testfilepath, false)
+	require.Nil(t, err)
+	assert.Equal(t, 1, len(lock.Resources()))
+
+	sum := sha256.Sum256([]byte(content))
+	cmd = NewRootCmd()
+	cmd.SetArgs([]string{"-f", testfilepath, "delete", "--integrity", "sha256:" + hex.EncodeToString(sum[:]), "--dir", outputDir, "--remove-files"})
+	err = cmd.Execute()
+	assert.Nil(t, err)
+	assert.NoFileExists(t, filepath.Join(outputDir, "test2.html"))
+	lock, err = internal.NewLock(testfilepath, false)
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(lock.Resources()))
+}
+
+func TestRunDeleteInvalidFlags(t *testing.T) {
+	testfilepath := test.TmpFile(t, `
+	[[Resource]]
+	Urls = ['http://localhost:123456/test.html']
+	Integrity = 'sha256-asdasdasd'
+`)
+	for _, args := range [][]string{
+		{"delete"},
+		{"delete", "--notag", "tag"},
+		{"delete", "http://localhost:123456/test.html", "--remove-files"},
+		{"delete", "--integrity", "invalid"},
+	} {
+		cmd := NewRootCmd()
+		cmd.SetArgs(append([]string{"-f", testfilepath}, args...))
+		err := cmd.Execute()
+		assert.NotNil(t, err, args)
+	}
+}
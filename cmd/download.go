@@ -4,7 +4,12 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
+	"runtime"
+
 	"github.com/cisco-open/grabit/internal"
+	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 )
 
@@ -21,6 +26,17 @@ func addDownload(cmd *cobra.Command) {
 	downloadCmd.Flags().StringArray("tag", []string{}, "Only download the resources with the given tag")
 	downloadCmd.Flags().StringArray("notag", []string{}, "Only download the resources without the given tag")
 	downloadCmd.Flags().String("perm", "", "Optional permissions for the downloaded files (e.g. '644')")
+	downloadCmd.Flags().Bool("no-cache", false, "Do not use the local download cache")
+	downloadCmd.Flags().Int("retries", internal.DefaultRetryPolicy.Retries, "Number of retries of downloads failing with a transient error (5xx, 429, timeout, connection reset)")
+	downloadCmd.Flags().Duration("retry-backoff", internal.DefaultRetryPolicy.Backoff, "Delay before the first retry, doubled with every retry")
+	downloadCmd.Flags().Duration("retry-max-backoff", internal.DefaultRetryPolicy.MaxBackoff, "Maximum delay between retries")
+	downloadCmd.Flags().Float64("retry-jitter", internal.DefaultRetryPolicy.Jitter, "Fraction of the delay between retries randomly added or removed")
+	downloadCmd.Flags().IntP("jobs", "j", runtime.NumCPU(), "Number of resources downloaded simultaneously")
+	downloadCmd.Flags().Int("jobs-per-host", 0, "Maximum number of simultaneous connections to a single host (0 for no limit)")
+	downloadCmd.Flags().Bool("fail-fast", false, "Cancel the remaining downloads on the first failure")
+	downloadCmd.Flags().Bool("offline", false, "Fail instead of accessing the network when a resource is not in the target directory or the local cache")
+	downloadCmd.Flags().Bool("require-signed-lock", false, "Refuse to download anything unless the lock file has a valid signature")
+	addLockSignatureFlags(downloadCmd, "lock-")
 	cmd.AddCommand(downloadCmd)
 }
 
@@ -33,6 +49,16 @@ func runFetch(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	requireSignedLock, err := cmd.Flags().GetBool("require-signed-lock")
+	if err != nil {
+		return err
+	}
+	if requireSignedLock {
+		err = verifyLockSignature(cmd, lock, lockFile, "lock-")
+		if err != nil {
+			return err
+		}
+	}
 	dir, err := cmd.Flags().GetString("dir")
 	if err != nil {
 		return err
@@ -53,9 +79,72 @@ func runFetch(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	err = lock.Download(dir, tags, notags, perm, status)
+	output, err := getOutput(cmd)
+	if err != nil {
+		return err
+	}
+	if status && output == OUTPUT_JSON {
+		return fmt.Errorf("--status cannot be used with --output %s", OUTPUT_JSON)
+	}
+	noCache, err := cmd.Flags().GetBool("no-cache")
+	if err != nil {
+		return err
+	}
+	offline, err := cmd.Flags().GetBool("offline")
+	if err != nil {
+		return err
+	}
+	retry := internal.DefaultRetryPolicy
+	retry.Retries, err = cmd.Flags().GetInt("retries")
+	if err != nil {
+		return err
+	}
+	retry.Backoff, err = cmd.Flags().GetDuration("retry-backoff")
 	if err != nil {
 		return err
 	}
-	return nil
+	retry.MaxBackoff, err = cmd.Flags().GetDuration("retry-max-backoff")
+	if err != nil {
+		return err
+	}
+	retry.Jitter, err = cmd.Flags().GetFloat64("retry-jitter")
+	if err != nil {
+		return err
+	}
+	jobs, err := cmd.Flags().GetInt("jobs")
+	if err != nil {
+		return err
+	}
+	jobsPerHost, err := cmd.Flags().GetInt("jobs-per-host")
+	if err != nil {
+		return err
+	}
+	failFast, err := cmd.Flags().GetBool("fail-fast")
+	if err != nil {
+		return err
+	}
+	opts := internal.DownloadOptions{Offline: offline, Retry: retry, Jobs: jobs, JobsPerHost: jobsPerHost, FailFast: failFast}
+	if !noCache {
+		opts.Cache, err = getCache(cmd)
+		if err != nil {
+			// The cache is an optimization: download without it.
+			log.Warn().Msgf("Not using the local download cache: %v", err)
+		}
+	}
+	var results []internal.DownloadResult
+	err = runInterruptible(cmd.Context(), func(ctx context.Context) error {
+		results, err = lock.Download(dir, tags, notags, perm, status, opts, ctx)
+		return err
+	})
+	if output == OUTPUT_JSON && results != nil {
+		downloads := []downloadJSON{}
+		for _, r := range results {
+			downloads = append(downloads, newDownloadJSON(r))
+		}
+		jsonErr := printJSON(cmd, downloads)
+		if err == nil {
+			err = jsonErr
+		}
+	}
+	return err
 }
@@ -1,11 +1,18 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/cisco-open/grabit/internal"
 	"github.com/cisco-open/grabit/test"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRunDownload(t *testing.T) {
@@ -31,6 +38,25 @@ func TestRunDownload(t *testing.T) {
 	}
 }
 
+func TestRunDownloadWithoutCacheDir(t *testing.T) {
+	t.Setenv("HOME", "")
+	t.Setenv("XDG_CACHE_HOME", "")
+	t.Setenv(internal.GRABIT_CACHE_DIR_ENV_VAR, "")
+	content := `abcdef`
+	port := test.TestHttpHandler(content, t)
+	testfilepath := test.TmpFile(t, fmt.Sprintf(`
+	[[Resource]]
+	Urls = ['http://localhost:%d/test.html']
+	Integrity = '%s'
+`, port, test.GetSha256Integrity(content)))
+	outputDir := test.TmpDir(t)
+	cmd := NewRootCmd()
+	cmd.SetArgs([]string{"-f", testfilepath, "download", "--dir", outputDir})
+	err := cmd.Execute()
+	assert.Nil(t, err)
+	test.AssertFileContains(t, filepath.Join(outputDir, "test.html"), content)
+}
+
 func TestRunDownloadWithTags(t *testing.T) {
 	content := `abcdef`
 	contentIntegrity := test.GetSha256Integrity(content)
@@ -134,3 +160,186 @@ func TestRunDownloadTriesAllUrls(t *testing.T) {
 		test.AssertFileContains(t, fmt.Sprintf("%s/%s", outputDir, file), content)
 	}
 }
+
+func TestRunDownloadOffline(t *testing.T) {
+	content := `abcdef`
+	contentIntegrity := test.GetSha256Integrity(content)
+	port, server := test.TestHttpHandlerWithServer(content, t)
+	testfilepath := test.TmpFile(t, fmt.Sprintf(`
+	[[Resource]]
+	Urls = ['http://localhost:%d/test.html']
+	Integrity = '%s'
+`, port, contentIntegrity))
+	cacheDir := test.TmpDir(t)
+	outputDir := test.TmpDir(t)
+	cmd := NewRootCmd()
+	cmd.SetArgs([]string{"-f", testfilepath, "--cache-dir", cacheDir, "download", "--offline", "--dir", outputDir})
+	err := cmd.Execute()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "cannot be satisfied offline")
+
+	// Populate the cache, then download offline from it.
+	cmd = NewRootCmd()
+	cmd.SetArgs([]string{"-f", testfilepath, "--cache-dir", cacheDir, "download", "--dir", test.TmpDir(t)})
+	err = cmd.Execute()
+	assert.Nil(t, err)
+	server.Close()
+	cmd = NewRootCmd()
+	cmd.SetArgs([]string{"-f", testfilepath, "--cache-dir", cacheDir, "download", "--offline", "--dir", outputDir})
+	err = cmd.Execute()
+	assert.Nil(t, err)
+	test.AssertFileContains(t, fmt.Sprintf("%s/%s", outputDir, "test.html"), content)
+}
+
+func TestRunDownloadWithRetries(t *testing.T) {
+	content := `abcdef`
+	contentIntegrity := test.GetSha256Integrity(content)
+	calls := 0
+	port, server := test.HttpHandler(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(content))
+	})
+	t.Cleanup(server.Close)
+	testfilepath := test.TmpFile(t, fmt.Sprintf(`
+	[[Resource]]
+	Urls = ['http://localhost:%d/test.html']
+	Integrity = '%s'
+`, port, contentIntegrity))
+	outputDir := test.TmpDir(t)
+	cmd := NewRootCmd()
+	cmd.SetArgs([]string{"-f", testfilepath, "download", "--dir", outputDir, "--no-cache", "--retries", "1", "--retry-backoff", "1ms"})
+	err := cmd.Execute()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, calls)
+	test.AssertFileContains(t, fmt.Sprintf("%s/%s", outputDir, "test.html"), content)
+}
+
+func TestRunDownloadFailFast(t *testing.T) {
+	content := `abcdef`
+	contentIntegrity := test.GetSha256Integrity(content)
+	port := test.TestHttpHandler(content, t)
+	testfilepath := test.TmpFile(t, fmt.Sprintf(`
+	[[Resource]]
+	Urls = ['http://localhost:%d/test.html']
+	Integrity = 'sha256-bogus'
+
+	[[Resource]]
+	Urls = ['http://localhost:%d/test2.html']
+	Integrity = '%s'
+`, port, port, contentIntegrity))
+	outputDir := test.TmpDir(t)
+	cmd := NewRootCmd()
+	cmd.SetArgs([]string{"-f", testfilepath, "download", "--dir", outputDir, "--no-cache", "--jobs", "1", "--fail-fast"})
+	err := cmd.Execute()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "integrity mismatch")
+	assert.Contains(t, err.Error(), "1 resources skipped because of an earlier failure")
+	assert.NoFileExists(t, fmt.Sprintf("%s/%s", outputDir, "test2.html"))
+}
+
+func TestRunDownloadJSON(t *testing.T) {
+	content := `abcdef`
+	contentIntegrity := test.GetSha256Integrity(content)
+	port := test.TestHttpHandler(content, t)
+	testfilepath := test.TmpFile(t, fmt.Sprintf(`
+	[[Resource]]
+	Urls = ['http://localhost:%d/test.html']
+	Integrity = '%s'
+	Tags = ['tag']
+
+	[[Resource]]
+	Urls = ['http://localhost:%d/test2.html']
+	Integrity = '%s'
+`, port, contentIntegrity, port, test.GetSha256Integrity("invalid")))
+	outputDir := test.TmpDir(t)
+	cmd := NewRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"-f", testfilepath, "--output", "json", "download", "--dir", outputDir, "--no-cache"})
+	err := cmd.Execute()
+	assert.ErrorContains(t, err, "integrity mismatch")
+	var results []map[string]any
+	err = json.Unmarshal(buf.Bytes(), &results)
+	require.Nil(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, []any{fmt.Sprintf("http://localhost:%d/test.html", port)}, results[0]["urls"])
+	assert.Equal(t, contentIntegrity, results[0]["integrity"])
+	assert.Equal(t, []any{"tag"}, results[0]["tags"])
+	assert.Equal(t, filepath.Join(outputDir, "test.html"), results[0]["path"])
+	assert.Equal(t, fmt.Sprintf("http://localhost:%d/test.html", port), results[0]["url"])
+	assert.Equal(t, float64(len(content)), results[0]["bytes"])
+	assert.Equal(t, false, results[0]["cache_hit"])
+	assert.Contains(t, results[0], "duration_ms")
+	assert.NotContains(t, results[0], "error")
+	assert.Contains(t, results[1]["error"], "integrity mismatch")
+
+	cmd = NewRootCmd()
+	cmd.SetArgs([]string{"-f", testfilepath, "--output", "json", "download", "--dir", outputDir, "--status"})
+	err = cmd.Execute()
+	assert.ErrorContains(t, err, "--status cannot be used with --output json")
+
+	cmd = NewRootCmd()
+	cmd.SetArgs([]string{"-f", testfilepath, "--output", "yaml", "download", "--dir", outputDir})
+	err = cmd.Execute()
+	assert.ErrorContains(t, err, "unknown output format 'yaml'")
+}
+
+func TestRunDownloadWithPathsRelativeToLockFile(t *testing.T) {
+	content := "abcdef"
+	identity := "https://github.com/example/tool/.github/workflows/release.yml@refs/tags/v1.0"
+	issuer := "https://token.actions.githubusercontent.com"
+	signer, publicKey := test.NewOpenPGPKey(t)
+	signature := test.OpenPGPSign(t, signer, content)
+	sigstore, trustedRoot := test.NewSigstore(t)
+	bundle := test.SigstoreSign(t, sigstore, identity, issuer, content)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		body := content
+		switch r.URL.Path {
+		case "/test.html.asc":
+			body = signature
+		case "/test.html.sigstore.json":
+			body = bundle
+		}
+		_, err := w.Write([]byte(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	port, server := test.HttpHandler(handler)
+	t.Cleanup(server.Close)
+	project := test.TmpDir(t)
+	keys := filepath.Join(project, "keys")
+	require.Nil(t, os.Mkdir(keys, 0755))
+	require.Nil(t, os.WriteFile(filepath.Join(keys, "keyring.asc"), []byte(publicKey), 0644))
+	trustedRootContent, err := os.ReadFile(trustedRoot)
+	require.Nil(t, err)
+	require.Nil(t, os.WriteFile(filepath.Join(keys, "trusted_root.json"), trustedRootContent, 0644))
+
+	// The paths given relative to the current directory are stored
+	// relative to the lock file.
+	t.Chdir(keys)
+	cmd := NewRootCmd()
+	cmd.SetArgs([]string{"-f", "../grabit.lock", "add", fmt.Sprintf("http://localhost:%d/test.html", port),
+		"--signature-url", fmt.Sprintf("http://localhost:%d/test.html.asc", port), "--keyring", "keyring.asc",
+		"--sigstore-bundle-url", fmt.Sprintf("http://localhost:%d/test.html.sigstore.json", port), "--sigstore-trusted-root", "trusted_root.json",
+		"--sigstore-identity", identity, "--sigstore-issuer", issuer})
+	err = cmd.Execute()
+	require.Nil(t, err)
+	lockFile := filepath.Join(project, "grabit.lock")
+	lockContent, err := os.ReadFile(lockFile)
+	require.Nil(t, err)
+	assert.Contains(t, string(lockContent), "Keyring = 'keys/keyring.asc'")
+	assert.Contains(t, string(lockContent), "SigstoreTrustedRoot = 'keys/trusted_root.json'")
+
+	t.Chdir(test.TmpDir(t))
+	outputDir := test.TmpDir(t)
+	cmd = NewRootCmd()
+	cmd.SetArgs([]string{"-f", lockFile, "download", "--dir", outputDir, "--no-cache"})
+	err = cmd.Execute()
+	assert.Nil(t, err)
+	test.AssertFileContains(t, filepath.Join(outputDir, "test.html"), content)
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/rs/zerolog/log"
+)
+
+// interruptedError is the error of a command interrupted by a signal.
+type interruptedError struct {
+	signal os.Signal
+	err    error
+}
+
+func (e *interruptedError) Error() string { return e.err.Error() }
+
+func (e *interruptedError) Unwrap() error { return e.err }
+
+// exitCode returns the exit code of the interrupted command: 128 plus the
+// number of the signal, as shells do.
+func (e *interruptedError) exitCode() int {
+	if s, ok := e.signal.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+	return 128 + int(syscall.SIGINT)
+}
+
+// runInterruptible runs f with a context cancelled upon reception of an
+// interrupt signal, so that it can clean up after itself. A second signal
+// exits immediately, as do the commands not run by runInterruptible.
+func runInterruptible(ctx context.Context, f func(ctx context.Context) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	var received os.Signal
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		select {
+		case received = <-signals:
+			log.Warn().Msg("Interrupt signal received. Exiting.")
+			// Restore the default behavior of the signals.
+			signal.Stop(signals)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	err := f(ctx)
+	cancel()
+	<-done
+	if err != nil && received != nil {
+		return &interruptedError{signal: received, err: err}
+	}
+	return err
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunInterruptible(t *testing.T) {
+	err := runInterruptible(context.Background(), func(ctx context.Context) error {
+		return errors.New("failed")
+	})
+	assert.EqualError(t, err, "failed")
+
+	err = runInterruptible(context.Background(), func(ctx context.Context) error {
+		p, err := os.FindProcess(os.Getpid())
+		require.Nil(t, err)
+		require.Nil(t, p.Signal(syscall.SIGTERM))
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	var interrupted *interruptedError
+	require.True(t, errors.As(err, &interrupted))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 143, interrupted.exitCode())
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cisco-open/grabit/internal"
+	"github.com/spf13/cobra"
+)
+
+func addList(cmd *cobra.Command) {
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the resources of the lock file",
+		Args:  cobra.NoArgs,
+		RunE:  runList,
+	}
+	listCmd.Flags().StringArray("tag", []string{}, "Only list the resources with the given tag")
+	listCmd.Flags().StringArray("notag", []string{}, "Only list the resources without the given tag")
+	listCmd.Flags().Bool("json", false, "Print the resources as JSON (same as '--output json')")
+	cmd.AddCommand(listCmd)
+}
+
+func runList(cmd *cobra.Command, args []string) error {
+	lockFile, err := cmd.Flags().GetString("lock-file")
+	if err != nil {
+		return err
+	}
+	lock, err := internal.NewLock(lockFile, false)
+	if err != nil {
+		return err
+	}
+	tags, err := cmd.Flags().GetStringArray("tag")
+	if err != nil {
+		return err
+	}
+	notags, err := cmd.Flags().GetStringArray("notag")
+	if err != nil {
+		return err
+	}
+	output, err := getOutput(cmd)
+	if err != nil {
+		return err
+	}
+	resources := lock.Filter(internal.NewTagFilter(tags, notags))
+	if output == OUTPUT_JSON {
+		listed := []listJSON{}
+		for _, r := range resources {
+			listed = append(listed, newListJSON(r))
+		}
+		return printJSON(cmd, listed)
+	}
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "URLS\tFILENAME\tTAGS\tALGORITHM\tCACHE URL")
+	for _, r := range resources {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", strings.Join(r.Urls, ","), orDash(r.Filename), orDash(strings.Join(r.Tags, ",")), integrityAlgo(r.Integrity), orDash(r.ArtifactoryCacheURL))
+	}
+	return w.Flush()
+}
+
+// integrityAlgo returns the algorithm of an SRI integrity.
+func integrityAlgo(integrity string) string {
+	algo, _, _ := strings.Cut(integrity, "-")
+	return algo
+}
+
+// orDash returns s, or "-" if it is empty, to keep the table columns aligned.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/cisco-open/grabit/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const listLock = `
+	[[Resource]]
+	Urls = ['http://localhost:123456/a.tar.gz']
+	Integrity = 'sha256-a'
+	Tags = ['tag1', 'tag2']
+	Filename = 'a.tgz'
+	ArtifactoryCacheURL = 'https://artifactory/cache'
+
+	[[Resource]]
+	Urls = ['http://localhost:123456/b.html', 'http://mirror/b.html']
+	Integrity = 'sha512-b'
+`
+
+func TestRunList(t *testing.T) {
+	testfilepath := test.TmpFile(t, listLock)
+	cmd := NewRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"-f", testfilepath, "list"})
+	err := cmd.Execute()
+	assert.Nil(t, err)
+	assert.Contains(t, buf.String(), "CACHE URL")
+	assert.Regexp(t, `http://localhost:123456/a.tar.gz\s+a.tgz\s+tag1,tag2\s+sha256\s+https://artifactory/cache`, buf.String())
+	assert.Regexp(t, `http://localhost:123456/b.html,http://mirror/b.html\s+-\s+-\s+sha512\s+-`, buf.String())
+
+	cmd = NewRootCmd()
+	buf = new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"-f", testfilepath, "list", "--notag", "tag2"})
+	err = cmd.Execute()
+	assert.Nil(t, err)
+	assert.NotContains(t, buf.String(), "a.tar.gz")
+	assert.Contains(t, buf.String(), "b.html")
+}
+
+func TestRunListJSON(t *testing.T) {
+	testfilepath := test.TmpFile(t, listLock)
+	cmd := NewRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"-f", testfilepath, "list", "--json", "--tag", "tag1"})
+	err := cmd.Execute()
+	require.Nil(t, err)
+	var results []map[string]any
+	require.Nil(t, json.Unmarshal(buf.Bytes(), &results))
+	assert.Equal(t, []map[string]any{{
+		"urls":                  []any{"http://localhost:123456/a.tar.gz"},
+		"integrity":             "sha256-a",
+		"tags":                  []any{"tag1", "tag2"},
+		"filename":              "a.tgz",
+		"algorithm":             "sha256",
+		"artifactory_cache_url": "https://artifactory/cache",
+	}}, results)
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cisco-open/grabit/internal"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func addLock(cmd *cobra.Command) {
+	lockCmd := &cobra.Command{
+		Use:   "lock",
+		Short: "Manage the lock file",
+	}
+	signCmd := &cobra.Command{
+		Use:   "sign",
+		Short: "Sign the lock file with a minisign key",
+		Args:  cobra.NoArgs,
+		RunE:  runLockSign,
+	}
+	signCmd.Flags().String("key", "", fmt.Sprintf("Minisign private key file, whose password is read from $%s", internal.LOCK_KEY_PASSWORD_ENV_VAR))
+	signCmd.Flags().String("signature", "", "Path of the signature (default: the lock file path followed by '.minisig')")
+	_ = signCmd.MarkFlagRequired("key")
+	verifyCmd := &cobra.Command{
+		Use:   "verify-signature",
+		Short: "Verify the signature of the lock file",
+		Args:  cobra.NoArgs,
+		RunE:  runLockVerifySignature,
+	}
+	addLockSignatureFlags(verifyCmd, "")
+	migrateCmd := &cobra.Command{
+		Use:   "migrate",
+		Short: fmt.Sprintf("Upgrade the lock file to the version %d of its schema", internal.LOCK_VERSION),
+		Args:  cobra.NoArgs,
+		RunE:  runLockMigrate,
+	}
+	lockCmd.AddCommand(signCmd, verifyCmd, migrateCmd)
+	cmd.AddCommand(lockCmd)
+}
+
+// addLockSignatureFlags adds the flags used to verify the signature of the
+// lock file, with the given name prefix.
+func addLockSignatureFlags(cmd *cobra.Command, prefix string) {
+	cmd.Flags().String(prefix+"public-key", "", "Minisign public key of the lock file signer")
+	cmd.Flags().String(prefix+"public-key-file", "", "Minisign public key file of the lock file signer")
+	cmd.MarkFlagsMutuallyExclusive(prefix+"public-key", prefix+"public-key-file")
+	cmd.Flags().String(prefix+"signature", "", "Path of the lock file signature (default: the lock file path followed by '.minisig')")
+}
+
+func runLockSign(cmd *cobra.Command, args []string) error {
+	lockFile, err := cmd.Flags().GetString("lock-file")
+	if err != nil {
+		return err
+	}
+	lock, err := internal.NewLock(lockFile, false)
+	if err != nil {
+		return err
+	}
+	key, err := cmd.Flags().GetString("key")
+	if err != nil {
+		return err
+	}
+	signature, err := cmd.Flags().GetString("signature")
+	if err != nil {
+		return err
+	}
+	if signature == "" {
+		signature = internal.LockSignaturePath(lockFile)
+	}
+	return lock.Sign(key, os.Getenv(internal.LOCK_KEY_PASSWORD_ENV_VAR), signature)
+}
+
+func runLockVerifySignature(cmd *cobra.Command, args []string) error {
+	lockFile, err := cmd.Flags().GetString("lock-file")
+	if err != nil {
+		return err
+	}
+	lock, err := internal.NewLock(lockFile, false)
+	if err != nil {
+		return err
+	}
+	err = verifyLockSignature(cmd, lock, lockFile, "")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Valid signature of '%s'\n", lockFile)
+	return nil
+}
+
+func runLockMigrate(cmd *cobra.Command, args []string) error {
+	lockFile, err := cmd.Flags().GetString("lock-file")
+	if err != nil {
+		return err
+	}
+	lock, err := internal.NewLockForUpdate(lockFile, false)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	from := lock.Migrate()
+	if from == lock.Version() {
+		fmt.Fprintf(cmd.OutOrStdout(), "Lock file '%s' is already at version %d\n", lockFile, from)
+		return nil
+	}
+	err = lock.Save()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Migrated lock file '%s' from version %d to %d\n", lockFile, from, lock.Version())
+	if _, err := os.Stat(internal.LockSignaturePath(lockFile)); err == nil {
+		log.Warn().Msgf("The lock file must be signed again: its signature '%s' is no longer valid", internal.LockSignaturePath(lockFile))
+	}
+	return nil
+}
+
+// verifyLockSignature checks the signature of the lock file against the
+// public key given with the flags of the given name prefix.
+func verifyLockSignature(cmd *cobra.Command, lock *internal.Lock, lockFile string, prefix string) error {
+	publicKey, err := cmd.Flags().GetString(prefix + "public-key")
+	if err != nil {
+		return err
+	}
+	publicKeyFile, err := cmd.Flags().GetString(prefix + "public-key-file")
+	if err != nil {
+		return err
+	}
+	if publicKeyFile != "" {
+		content, err := os.ReadFile(publicKeyFile)
+		if err != nil {
+			return fmt.Errorf("cannot read public key: %w", err)
+		}
+		publicKey = string(content)
+	}
+	if publicKey == "" {
+		return fmt.Errorf("--%spublic-key or --%spublic-key-file is required to verify the signature of the lock file", prefix, prefix)
+	}
+	signature, err := cmd.Flags().GetString(prefix + "signature")
+	if err != nil {
+		return err
+	}
+	if signature == "" {
+		signature = internal.LockSignaturePath(lockFile)
+	}
+	return lock.VerifySignature(publicKey, signature)
+}
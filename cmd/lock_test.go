@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cisco-open/grabit/internal"
+	"github.com/cisco-open/grabit/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunLockSignAndVerify(t *testing.T) {
+	lockFile := test.TmpFile(t, `
+	[[Resource]]
+	Urls = ['http://localhost:123456/test.html']
+	Integrity = 'sha256-asdasdasd'
+`)
+	keyFile, publicKey := test.NewMinisignKeyFile(t, "secret")
+	t.Setenv(internal.LOCK_KEY_PASSWORD_ENV_VAR, "secret")
+	cmd := NewRootCmd()
+	cmd.SetArgs([]string{"-f", lockFile, "lock", "sign", "--key", keyFile})
+	err := cmd.Execute()
+	require.Nil(t, err)
+	assert.FileExists(t, lockFile+".minisig")
+
+	cmd = NewRootCmd()
+	out := &bytes.Buffer{}
+	cmd.SetOut(out)
+	cmd.SetArgs([]string{"-f", lockFile, "lock", "verify-signature", "--public-key-file", test.TmpFile(t, publicKey)})
+	err = cmd.Execute()
+	assert.Nil(t, err)
+	assert.Contains(t, out.String(), "Valid signature")
+
+	_, otherPublicKey := test.NewMinisignKeyFile(t, "secret")
+	cmd = NewRootCmd()
+	cmd.SetArgs([]string{"-f", lockFile, "lock", "verify-signature", "--public-key-file", test.TmpFile(t, otherPublicKey)})
+	err = cmd.Execute()
+	assert.ErrorContains(t, err, "invalid signature")
+
+	cmd = NewRootCmd()
+	cmd.SetArgs([]string{"-f", lockFile, "lock", "verify-signature"})
+	err = cmd.Execute()
+	assert.ErrorContains(t, err, "--public-key or --public-key-file is required")
+}
+
+func TestRunDownloadRequireSignedLock(t *testing.T) {
+	content := `abcdef`
+	port := test.TestHttpHandler(content, t)
+	lockFile := test.TmpFile(t, fmt.Sprintf(`
+	[[Resource]]
+	Urls = ['http://localhost:%d/test.html']
+	Integrity = '%s'
+`, port, test.GetSha256Integrity(content)))
+	keyFile, publicKey := test.NewMinisignKeyFile(t, "")
+	publicKeyFile := test.TmpFile(t, publicKey)
+
+	outputDir := test.TmpDir(t)
+	cmd := NewRootCmd()
+	cmd.SetArgs([]string{"-f", lockFile, "download", "--dir", outputDir, "--require-signed-lock", "--lock-public-key-file", publicKeyFile})
+	err := cmd.Execute()
+	assert.ErrorContains(t, err, "is not signed")
+	files, err := os.ReadDir(outputDir)
+	require.Nil(t, err)
+	assert.Empty(t, files)
+
+	signature := filepath.Join(test.TmpDir(t), "lock.sig")
+	cmd = NewRootCmd()
+	cmd.SetArgs([]string{"-f", lockFile, "lock", "sign", "--key", keyFile, "--signature", signature})
+	err = cmd.Execute()
+	require.Nil(t, err)
+	cmd = NewRootCmd()
+	cmd.SetArgs([]string{"-f", lockFile, "download", "--dir", outputDir, "--require-signed-lock", "--lock-public-key-file", publicKeyFile, "--lock-signature", signature})
+	err = cmd.Execute()
+	assert.Nil(t, err)
+	test.AssertFileContains(t, filepath.Join(outputDir, "test.html"), content)
+
+	err = os.WriteFile(lockFile, []byte(fmt.Sprintf(`
+	[[Resource]]
+	Urls = ['http://localhost:%d/test.html']
+	Integrity = '%s'
+`, port, test.GetSha256Integrity("tampered"))), 0644)
+	require.Nil(t, err)
+	cmd = NewRootCmd()
+	cmd.SetArgs([]string{"-f", lockFile, "download", "--dir", test.TmpDir(t), "--require-signed-lock", "--lock-public-key-file", publicKeyFile, "--lock-signature", signature})
+	err = cmd.Execute()
+	assert.ErrorContains(t, err, "invalid signature")
+}
+
+func TestRunLockMigrate(t *testing.T) {
+	testfilepath := test.TmpFile(t, `
+	[[Resource]]
+	Urls = ['http://localhost:123456/test.html']
+	Integrity = 'sha256-asdasdasd'
+`)
+	cmd := NewRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"-f", testfilepath, "lock", "migrate"})
+	err := cmd.Execute()
+	assert.Nil(t, err)
+	assert.Contains(t, buf.String(), fmt.Sprintf("from version 0 to %d", internal.LOCK_VERSION))
+	lock, err := internal.NewLock(testfilepath, false)
+	require.Nil(t, err)
+	assert.Equal(t, internal.LOCK_VERSION, lock.Version())
+
+	cmd = NewRootCmd()
+	buf = new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"-f", testfilepath, "lock", "migrate"})
+	err = cmd.Execute()
+	assert.Nil(t, err)
+	assert.Contains(t, buf.String(), "is already at version")
+}
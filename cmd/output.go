@@ -0,0 +1,196 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cisco-open/grabit/internal"
+	"github.com/spf13/cobra"
+)
+
+// Output formats of the commands.
+const (
+	OUTPUT_TEXT = "text"
+	OUTPUT_JSON = "json"
+)
+
+// getOutput returns the output format selected by the command flags. The
+// --json flag of some commands is a shortcut for '--output json'.
+func getOutput(cmd *cobra.Command) (string, error) {
+	if asJSON, err := cmd.Flags().GetBool("json"); err == nil && asJSON {
+		return OUTPUT_JSON, nil
+	}
+	output, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return "", err
+	}
+	output = strings.ToLower(output)
+	if output != OUTPUT_TEXT && output != OUTPUT_JSON {
+		return "", fmt.Errorf("unknown output format '%s' (available formats: %s, %s)", output, OUTPUT_TEXT, OUTPUT_JSON)
+	}
+	return output, nil
+}
+
+// jsonPrinted is the annotation of the commands that printed their
+// results as JSON.
+const jsonPrinted = "json-printed"
+
+// printJSON writes v as indented JSON to the command output.
+func printJSON(cmd *cobra.Command, v any) error {
+	if cmd.Annotations == nil {
+		cmd.Annotations = map[string]string{}
+	}
+	cmd.Annotations[jsonPrinted] = "true"
+	encoder := json.NewEncoder(cmd.OutOrStdout())
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// errorString returns the message of err, or an empty string if it is nil.
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// printJSONError writes err as JSON to the output of the failed command,
+// unless it already printed its results, which hold their errors.
+func printJSONError(cmd *cobra.Command, err error) {
+	output, outputErr := getOutput(cmd)
+	if outputErr != nil || output != OUTPUT_JSON || cmd.Annotations[jsonPrinted] != "" {
+		return
+	}
+	_ = printJSON(cmd, newErrorJSON(err))
+}
+
+// errorJSON is the JSON representation of the error of a command. The
+// errors of several resources are listed in Errors.
+type errorJSON struct {
+	Error  string      `json:"error"`
+	Url    string      `json:"url,omitempty"`
+	Errors []errorJSON `json:"errors,omitempty"`
+}
+
+func newErrorJSON(err error) errorJSON {
+	res := errorJSON{Error: err.Error()}
+	var resourceErr *internal.ResourceError
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			res.Errors = append(res.Errors, newErrorJSON(e))
+		}
+	} else if errors.As(err, &resourceErr) {
+		res.Url = resourceErr.Url
+	}
+	return res
+}
+
+// resourceJSON is the JSON representation of a resource.
+type resourceJSON struct {
+	Urls      []string `json:"urls"`
+	Integrity string   `json:"integrity"`
+	Tags      []string `json:"tags,omitempty"`
+	Filename  string   `json:"filename,omitempty"`
+	Comment   string   `json:"comment,omitempty"`
+}
+
+func newResourceJSON(r internal.Resource) resourceJSON {
+	return resourceJSON{Urls: r.Urls, Integrity: r.Integrity, Tags: r.Tags, Filename: r.Filename, Comment: r.Comment}
+}
+
+// downloadJSON is the JSON representation of the download of a resource.
+type downloadJSON struct {
+	resourceJSON
+	Path       string `json:"path,omitempty"`
+	Url        string `json:"url,omitempty"`
+	Bytes      int64  `json:"bytes"`
+	DurationMs int64  `json:"duration_ms"`
+	CacheHit   bool   `json:"cache_hit"`
+	Error      string `json:"error,omitempty"`
+}
+
+func newDownloadJSON(r internal.DownloadResult) downloadJSON {
+	return downloadJSON{
+		resourceJSON: newResourceJSON(r.Resource),
+		Path:         r.Path,
+		Url:          r.Url,
+		Bytes:        r.Bytes,
+		DurationMs:   r.Duration.Milliseconds(),
+		CacheHit:     r.CacheHit,
+		Error:        errorString(r.Err),
+	}
+}
+
+// verifyJSON is the JSON representation of the verification of a resource.
+type verifyJSON struct {
+	resourceJSON
+	Status   string   `json:"status"`
+	Paths    []string `json:"paths"`
+	Added    []string `json:"added,omitempty"`
+	Removed  []string `json:"removed,omitempty"`
+	Modified []string `json:"modified,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+func newVerifyJSON(r internal.VerifyResult) verifyJSON {
+	res := verifyJSON{
+		resourceJSON: newResourceJSON(r.Resource),
+		Status:       string(r.Status),
+		Paths:        r.Paths,
+		Error:        errorString(r.Err),
+	}
+	if r.Diff != nil {
+		res.Added, res.Removed, res.Modified = r.Diff.Added, r.Diff.Removed, r.Diff.Modified
+	}
+	return res
+}
+
+// updateJSON is the JSON representation of the update of a resource.
+type updateJSON struct {
+	resourceJSON
+	OldIntegrity string `json:"old_integrity"`
+	NewIntegrity string `json:"new_integrity"`
+	Changed      bool   `json:"changed"`
+}
+
+func newUpdateJSON(r internal.UpdateResult) updateJSON {
+	return updateJSON{
+		resourceJSON: newResourceJSON(r.Resource),
+		OldIntegrity: r.OldIntegrity,
+		NewIntegrity: r.NewIntegrity,
+		Changed:      r.Changed(),
+	}
+}
+
+// cacheEntryJSON is the JSON representation of a cached resource.
+type cacheEntryJSON struct {
+	Integrity string    `json:"integrity"`
+	Path      string    `json:"path"`
+	Bytes     int64     `json:"bytes"`
+	ModTime   time.Time `json:"mod_time"`
+}
+
+func newCacheEntryJSON(e internal.CacheEntry) cacheEntryJSON {
+	return cacheEntryJSON{Integrity: e.Integrity, Path: e.Path, Bytes: e.Size, ModTime: e.ModTime}
+}
+
+// listJSON is the JSON representation of a resource listed by 'grabit list'.
+type listJSON struct {
+	resourceJSON
+	Algorithm           string `json:"algorithm"`
+	ArtifactoryCacheURL string `json:"artifactory_cache_url,omitempty"`
+}
+
+func newListJSON(r internal.Resource) listJSON {
+	return listJSON{
+		resourceJSON:        newResourceJSON(r),
+		Algorithm:           integrityAlgo(r.Integrity),
+		ArtifactoryCacheURL: r.ArtifactoryCacheURL,
+	}
+}
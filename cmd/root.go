@@ -4,10 +4,12 @@
 package cmd
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/cisco-open/grabit/internal"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
@@ -21,9 +23,17 @@ func NewRootCmd() *cobra.Command {
 	}
 	cmd.PersistentFlags().StringP("lock-file", "f", filepath.Join(getPwd(), GRAB_LOCK), "lockfile path (default: $PWD/grabit.lock")
 	cmd.PersistentFlags().StringP("log-level", "l", "info", "log level (trace, debug, info, warn, error, fatal)")
+	cmd.PersistentFlags().String("cache-dir", "", "local download cache directory (default: $GRABIT_CACHE_DIR or $XDG_CACHE_HOME/grabit)")
+	cmd.PersistentFlags().StringP("output", "o", OUTPUT_TEXT, "output format of the results (text, json)")
+	addCache(cmd)
+	addClean(cmd)
 	addDelete(cmd)
 	addDownload(cmd)
 	addAdd(cmd)
+	addList(cmd)
+	addLock(cmd)
+	addUpdate(cmd)
+	addVerify(cmd)
 	addVersion(cmd)
 	return cmd
 }
@@ -38,6 +48,21 @@ func getPwd() string {
 
 var GRAB_LOCK = "grabit.lock"
 
+// getCache returns the local download cache selected by the command flags.
+func getCache(cmd *cobra.Command) (*internal.Cache, error) {
+	dir, err := cmd.Flags().GetString("cache-dir")
+	if err != nil {
+		return nil, err
+	}
+	if dir == "" {
+		dir, err = internal.DefaultCacheDir()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return internal.NewCache(dir)
+}
+
 func initLog(ll string) {
 	zerolog.SetGlobalLevel(zerolog.InfoLevel)
 	switch strings.ToLower(ll) {
@@ -64,7 +89,13 @@ func Execute(rootCmd *cobra.Command) {
 		log.Fatal().Msg(err.Error())
 	}
 	initLog(ll)
-	if err := rootCmd.Execute(); err != nil {
+	if cmd, err := rootCmd.ExecuteC(); err != nil {
+		printJSONError(cmd, err)
+		var interrupted *interruptedError
+		if errors.As(err, &interrupted) {
+			log.Error().Msg(err.Error())
+			os.Exit(interrupted.exitCode())
+		}
 		if strings.Contains(err.Error(), "unknown flag") {
 			// exit code 126: Command invoked cannot execute
 			os.Exit(126)
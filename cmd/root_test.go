@@ -2,11 +2,25 @@ package cmd
 
 import (
 	"bytes"
+	"os"
 	"testing"
 
+	"github.com/cisco-open/grabit/internal"
 	"github.com/stretchr/testify/assert"
 )
 
+// TestMain keeps the tests from using the user-level download cache.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "grabit-cache")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv(internal.GRABIT_CACHE_DIR_ENV_VAR, dir)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
 func TestRunRoot(t *testing.T) {
 	rootCmd := NewRootCmd()
 	buf := new(bytes.Buffer)
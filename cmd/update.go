@@ -0,0 +1,90 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/cisco-open/grabit/internal"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func addUpdate(cmd *cobra.Command) {
+	updateCmd := &cobra.Command{
+		Use:   "update [url...]",
+		Short: "Download resources again and update their integrity",
+		Long:  "Download resources again and update their integrity. All the resources are updated when no url is given.",
+		RunE:  runUpdate,
+	}
+	updateCmd.Flags().StringArray("tag", []string{}, "Only update the resources with the given tag")
+	updateCmd.Flags().StringArray("notag", []string{}, "Only update the resources without the given tag")
+	updateCmd.Flags().Bool("dry-run", false, "Only report the integrity changes without modifying the lock file")
+	cmd.AddCommand(updateCmd)
+}
+
+func runUpdate(cmd *cobra.Command, args []string) error {
+	lockFile, err := cmd.Flags().GetString("lock-file")
+	if err != nil {
+		return err
+	}
+	lock, err := internal.NewLockForUpdate(lockFile, false)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	tags, err := cmd.Flags().GetStringArray("tag")
+	if err != nil {
+		return err
+	}
+	notags, err := cmd.Flags().GetStringArray("notag")
+	if err != nil {
+		return err
+	}
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return err
+	}
+	output, err := getOutput(cmd)
+	if err != nil {
+		return err
+	}
+	results, err := lock.Update(args, tags, notags, dryRun)
+	if err != nil {
+		return err
+	}
+	out := cmd.OutOrStdout()
+	changed := 0
+	updated := []updateJSON{}
+	for _, r := range results {
+		if r.Changed() {
+			changed++
+		}
+		if output == OUTPUT_JSON {
+			updated = append(updated, newUpdateJSON(r))
+		} else if r.Changed() {
+			fmt.Fprintf(out, "%-10s %s\n           old: %s\n           new: %s\n", "CHANGED", r.Resource.Urls[0], r.OldIntegrity, r.NewIntegrity)
+		} else {
+			fmt.Fprintf(out, "%-10s %s\n", "UNCHANGED", r.Resource.Urls[0])
+		}
+	}
+	if output == OUTPUT_JSON {
+		err = printJSON(cmd, updated)
+		if err != nil {
+			return err
+		}
+	}
+	if dryRun {
+		log.Info().Msgf("Dry run: %d resource(s) would be updated", changed)
+		return nil
+	}
+	if changed == 0 {
+		return nil
+	}
+	err = lock.Save()
+	if err != nil {
+		return err
+	}
+	return nil
+}
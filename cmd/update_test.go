@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/cisco-open/grabit/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunUpdate(t *testing.T) {
+	content := `abcdef`
+	contentIntegrity := test.GetSha256Integrity(content)
+	port := test.TestHttpHandler(content, t)
+	testfilepath := test.TmpFile(t, fmt.Sprintf(`
+	[[Resource]]
+	Urls = ['http://localhost:%d/test.html']
+	Integrity = 'sha256-outdated'
+	Tags = ['tag']
+`, port))
+	cmd := NewRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"-f", testfilepath, "update"})
+	err := cmd.Execute()
+	assert.Nil(t, err)
+	assert.Contains(t, buf.String(), "CHANGED")
+	assert.Contains(t, buf.String(), contentIntegrity)
+	lock, err := os.ReadFile(testfilepath)
+	assert.Nil(t, err)
+	assert.Contains(t, string(lock), contentIntegrity)
+	assert.Contains(t, string(lock), "tag")
+}
+
+func TestRunUpdateDryRun(t *testing.T) {
+	content := `abcdef`
+	port := test.TestHttpHandler(content, t)
+	testfilepath := test.TmpFile(t, fmt.Sprintf(`
+	[[Resource]]
+	Urls = ['http://localhost:%d/test.html']
+	Integrity = 'sha256-outdated'
+`, port))
+	cmd := NewRootCmd()
+	cmd.SetArgs([]string{"-f", testfilepath, "update", "--dry-run"})
+	err := cmd.Execute()
+	assert.Nil(t, err)
+	lock, err := os.ReadFile(testfilepath)
+	assert.Nil(t, err)
+	assert.Contains(t, string(lock), "sha256-outdated")
+}
+
+func TestRunUpdateJSONError(t *testing.T) {
+	testfilepath := test.TmpFile(t, `
+	[[Resource]]
+	Urls = ['http://localhost:123456/test.html']
+	Integrity = 'sha256-outdated'
+
+	[[Resource]]
+	Urls = ['http://localhost:123456/test2.html']
+	Integrity = 'sha256-outdated'
+`)
+	cmd := NewRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"-f", testfilepath, "-o", "json", "update"})
+	executed, err := cmd.ExecuteC()
+	require.NotNil(t, err)
+	printJSONError(executed, err)
+	var result errorJSON
+	err = json.Unmarshal(buf.Bytes(), &result)
+	assert.Nil(t, err)
+	require.Len(t, result.Errors, 2)
+	assert.Equal(t, "http://localhost:123456/test.html", result.Errors[0].Url)
+	assert.Contains(t, result.Errors[0].Error, "cannot update resource")
+	assert.Equal(t, "http://localhost:123456/test2.html", result.Errors[1].Url)
+}
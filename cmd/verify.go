@@ -0,0 +1,101 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/cisco-open/grabit/internal"
+	"github.com/spf13/cobra"
+)
+
+func addVerify(cmd *cobra.Command) {
+	verifyCmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Verify already downloaded resources without accessing the network",
+		Args:  cobra.NoArgs,
+		RunE:  runVerify,
+	}
+	verifyCmd.Flags().String("dir", ".", "Directory where the files were downloaded")
+	verifyCmd.Flags().StringArray("tag", []string{}, "Only verify the resources with the given tag")
+	verifyCmd.Flags().StringArray("notag", []string{}, "Only verify the resources without the given tag")
+	cmd.AddCommand(verifyCmd)
+}
+
+func runVerify(cmd *cobra.Command, args []string) error {
+	lockFile, err := cmd.Flags().GetString("lock-file")
+	if err != nil {
+		return err
+	}
+	lock, err := internal.NewLock(lockFile, false)
+	if err != nil {
+		return err
+	}
+	dir, err := cmd.Flags().GetString("dir")
+	if err != nil {
+		return err
+	}
+	tags, err := cmd.Flags().GetStringArray("tag")
+	if err != nil {
+		return err
+	}
+	notags, err := cmd.Flags().GetStringArray("notag")
+	if err != nil {
+		return err
+	}
+	output, err := getOutput(cmd)
+	if err != nil {
+		return err
+	}
+	results, err := lock.Verify(dir, tags, notags)
+	if results == nil {
+		return err
+	}
+	failed := 0
+	out := cmd.OutOrStdout()
+	verified := []verifyJSON{}
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+		}
+		if output == OUTPUT_JSON {
+			verified = append(verified, newVerifyJSON(r))
+			continue
+		}
+		if r.Err != nil {
+			fmt.Fprintf(out, "%-10s %s: %v\n", strings.ToUpper(string(r.Status)), r.Name(), r.Err)
+		} else {
+			fmt.Fprintf(out, "%-10s %s\n", strings.ToUpper(string(r.Status)), r.Name())
+		}
+		if r.Diff != nil {
+			printManifestDiff(out, r.Diff)
+		}
+	}
+	if output == OUTPUT_JSON {
+		err = printJSON(cmd, verified)
+		if err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("verification failed for %d out of %d resources", failed, len(results))
+	}
+	return nil
+}
+
+// printManifestDiff prints the extracted files that differ from the
+// manifest of a resource.
+func printManifestDiff(out io.Writer, diff *internal.ManifestDiff) {
+	for _, f := range diff.Added {
+		fmt.Fprintf(out, "  %-8s %s\n", "added", f)
+	}
+	for _, f := range diff.Removed {
+		fmt.Fprintf(out, "  %-8s %s\n", "removed", f)
+	}
+	for _, f := range diff.Modified {
+		fmt.Fprintf(out, "  %-8s %s\n", "modified", f)
+	}
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cisco-open/grabit/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunVerify(t *testing.T) {
+	content := `abcdef`
+	contentIntegrity := test.GetSha256Integrity(content)
+	testfilepath := test.TmpFile(t, fmt.Sprintf(`
+	[[Resource]]
+	Urls = ['http://localhost:33/test.html']
+	Integrity = '%s'
+
+	[[Resource]]
+	Urls = ['http://localhost:33/test2.html']
+	Integrity = '%s'
+
+	[[Resource]]
+	Urls = ['http://localhost:33/test3.html']
+	Integrity = '%s'
+`, contentIntegrity, contentIntegrity, contentIntegrity))
+	outputDir := test.TmpDir(t)
+	err := os.WriteFile(filepath.Join(outputDir, "test.html"), []byte(content), 0644)
+	assert.Nil(t, err)
+	err = os.WriteFile(filepath.Join(outputDir, "test2.html"), []byte("invalid"), 0644)
+	assert.Nil(t, err)
+	cmd := NewRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"-f", testfilepath, "verify", "--dir", outputDir})
+	err = cmd.Execute()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "verification failed for 2 out of 3 resources")
+	assert.Contains(t, buf.String(), "OK         test.html")
+	assert.Contains(t, buf.String(), "MISMATCH   test2.html")
+	assert.Contains(t, buf.String(), "MISSING    test3.html")
+}
+
+func TestRunVerifyWithTags(t *testing.T) {
+	content := `abcdef`
+	contentIntegrity := test.GetSha256Integrity(content)
+	testfilepath := test.TmpFile(t, fmt.Sprintf(`
+	[[Resource]]
+	Urls = ['http://localhost:33/test.html']
+	Integrity = '%s'
+	Tags = ['tag']
+
+	[[Resource]]
+	Urls = ['http://localhost:33/test2.html']
+	Integrity = '%s'
+`, contentIntegrity, contentIntegrity))
+	outputDir := test.TmpDir(t)
+	err := os.WriteFile(filepath.Join(outputDir, "test.html"), []byte(content), 0644)
+	assert.Nil(t, err)
+	cmd := NewRootCmd()
+	cmd.SetArgs([]string{"-f", testfilepath, "verify", "--tag", "tag", "--dir", outputDir})
+	err = cmd.Execute()
+	assert.Nil(t, err)
+}
+
+func TestRunVerifyWithManifest(t *testing.T) {
+	content := test.Archive(t, "tar.gz", []test.ArchiveEntry{
+		{Name: "pkg/README", Content: "readme"},
+		{Name: "pkg/LICENSE", Content: "license"},
+	})
+	port := test.TestHttpHandler(content, t)
+	lockFile := test.TmpFile(t, "")
+	cmd := NewRootCmd()
+	cmd.SetArgs([]string{"-f", lockFile, "add", fmt.Sprintf("http://localhost:%d/pkg.tar.gz", port), "--extract", "--manifest"})
+	err := cmd.Execute()
+	require.Nil(t, err)
+	lockContent, err := os.ReadFile(lockFile)
+	require.Nil(t, err)
+	assert.Contains(t, string(lockContent), fmt.Sprintf("'pkg/README' = '%s'", test.GetSha256Integrity("readme")))
+
+	dir := test.TmpDir(t)
+	cmd = NewRootCmd()
+	cmd.SetArgs([]string{"-f", lockFile, "download", "--dir", dir, "--no-cache"})
+	err = cmd.Execute()
+	require.Nil(t, err)
+	err = os.WriteFile(filepath.Join(dir, "pkg", "README"), []byte("tampered"), 0644)
+	require.Nil(t, err)
+	err = os.Remove(filepath.Join(dir, "pkg", "LICENSE"))
+	require.Nil(t, err)
+
+	cmd = NewRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"-f", lockFile, "verify", "--dir", dir})
+	err = cmd.Execute()
+	assert.ErrorContains(t, err, "verification failed for 1 out of 1 resources")
+	assert.Contains(t, buf.String(), "MODIFIED   pkg.tar.gz")
+	assert.Contains(t, buf.String(), "  removed  pkg/LICENSE")
+	assert.Contains(t, buf.String(), "  modified pkg/README")
+}
+
+func TestRunVerifyJSON(t *testing.T) {
+	content := `abcdef`
+	contentIntegrity := test.GetSha256Integrity(content)
+	testfilepath := test.TmpFile(t, fmt.Sprintf(`
+	[[Resource]]
+	Urls = ['http://localhost:33/test.html']
+	Integrity = '%s'
+
+	[[Resource]]
+	Urls = ['http://localhost:33/test2.html']
+	Integrity = '%s'
+`, contentIntegrity, contentIntegrity))
+	outputDir := test.TmpDir(t)
+	err := os.WriteFile(filepath.Join(outputDir, "test.html"), []byte(content), 0644)
+	require.Nil(t, err)
+	cmd := NewRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"-f", testfilepath, "-o", "json", "verify", "--dir", outputDir})
+	err = cmd.Execute()
+	assert.ErrorContains(t, err, "verification failed for 1 out of 2 resources")
+	var results []map[string]any
+	err = json.Unmarshal(buf.Bytes(), &results)
+	require.Nil(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "ok", results[0]["status"])
+	assert.Equal(t, []any{filepath.Join(outputDir, "test.html")}, results[0]["paths"])
+	assert.Equal(t, "missing", results[1]["status"])
+	assert.Contains(t, results[1]["error"], "is missing")
+}
@@ -0,0 +1,282 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const GRABIT_CACHE_DIR_ENV_VAR = "GRABIT_CACHE_DIR"
+
+// Cache is a local content-addressed store of downloaded resources, keyed
+// by their integrity. It is shared by all the lock files of a user.
+type Cache struct {
+	dir string
+}
+
+// CacheEntry describes a file stored in the cache.
+type CacheEntry struct {
+	Integrity string
+	Path      string
+	Size      int64
+	ModTime   time.Time
+}
+
+// DefaultCacheDir returns the directory of the user-level cache:
+// $GRABIT_CACHE_DIR if set, grabit/ in the user cache directory
+// ($XDG_CACHE_HOME on Linux) otherwise.
+func DefaultCacheDir() (string, error) {
+	dir := os.Getenv(GRABIT_CACHE_DIR_ENV_VAR)
+	if dir != "" {
+		return dir, nil
+	}
+	userDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot find user cache directory: %w", err)
+	}
+	return filepath.Join(userDir, "grabit"), nil
+}
+
+// NewCache returns the cache stored in dir, creating the directory if needed.
+func NewCache(dir string) (*Cache, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create cache directory '%s': %w", dir, err)
+	}
+	return &Cache{dir: dir}, nil
+}
+
+// Dir returns the directory where the cache is stored.
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// entryPath returns the path of the cache entry of the given integrity.
+// Entries are stored as <algo>/<hex digest>.
+func (c *Cache) entryPath(integrity string) (string, error) {
+	algo, err := getAlgoFromIntegrity(integrity)
+	if err != nil {
+		return "", err
+	}
+	digest, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(integrity, algo+"-"))
+	if err != nil {
+		return "", fmt.Errorf("invalid SRI '%s': %w", integrity, err)
+	}
+	return filepath.Join(c.dir, algo, hex.EncodeToString(digest)), nil
+}
+
+// Get copies the cache entry of the given integrity to dest. It returns
+// false if the cache has no valid entry for it.
+func (c *Cache) Get(integrity string, dest string) (bool, error) {
+	entry, err := c.entryPath(integrity)
+	if err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(entry); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	algo, _ := getAlgoFromIntegrity(integrity)
+	err = checkIntegrityFromFile(entry, algo, integrity, entry)
+	if err != nil {
+		// Drop corrupted entries so they get downloaded again.
+		os.Remove(entry)
+		return false, fmt.Errorf("removed corrupted cache entry: %w", err)
+	}
+	// Copy in the target directory so that the call to os.Rename is atomic.
+	tmpPath, err := copyToTempFile(entry, filepath.Dir(dest))
+	if err != nil {
+		return false, err
+	}
+	err = os.Rename(tmpPath, dest)
+	if err != nil {
+		os.Remove(tmpPath)
+		return false, err
+	}
+	// Keep track of the last use for pruning.
+	now := time.Now()
+	_ = os.Chtimes(entry, now, now)
+	return true, nil
+}
+
+// Put stores the file at path in the cache. The file integrity must have
+// been checked by the caller.
+func (c *Cache) Put(path string, integrity string) error {
+	entry, err := c.entryPath(integrity)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(entry); err == nil {
+		now := time.Now()
+		return os.Chtimes(entry, now, now)
+	}
+	err = os.MkdirAll(filepath.Dir(entry), 0755)
+	if err != nil {
+		return err
+	}
+	tmpPath, err := copyToTempFile(path, filepath.Dir(entry))
+	if err != nil {
+		return err
+	}
+	err = os.Chmod(tmpPath, 0644)
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	err = os.Rename(tmpPath, entry)
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// List returns all the entries of the cache, oldest first.
+func (c *Cache) List() ([]CacheEntry, error) {
+	entries := []CacheEntry{}
+	for algo := range algos {
+		files, err := os.ReadDir(filepath.Join(c.dir, algo))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		for _, f := range files {
+			if f.IsDir() || strings.HasPrefix(f.Name(), ".") {
+				continue
+			}
+			digest, err := hex.DecodeString(f.Name())
+			if err != nil {
+				continue
+			}
+			info, err := f.Info()
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, CacheEntry{
+				Integrity: fmt.Sprintf("%s-%s", algo, base64.StdEncoding.EncodeToString(digest)),
+				Path:      filepath.Join(c.dir, algo, f.Name()),
+				Size:      info.Size(),
+				ModTime:   info.ModTime(),
+			})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ModTime.Before(entries[j].ModTime)
+	})
+	return entries, nil
+}
+
+// Verify checks the integrity of every entry of the cache and returns
+// the corrupted ones.
+func (c *Cache) Verify() ([]CacheEntry, error) {
+	entries, err := c.List()
+	if err != nil {
+		return nil, err
+	}
+	corrupted := []CacheEntry{}
+	errs := []error{}
+	for _, e := range entries {
+		algo, _ := getAlgoFromIntegrity(e.Integrity)
+		err := checkIntegrityFromFile(e.Path, algo, e.Integrity, e.Path)
+		if err != nil {
+			corrupted = append(corrupted, e)
+			errs = append(errs, err)
+		}
+	}
+	return corrupted, errors.Join(errs...)
+}
+
+// Prune removes the entries that were not used for longer than maxAge,
+// then the least recently used ones until the cache is no larger than
+// maxSize bytes. A zero maxAge or maxSize disables the matching limit.
+// The removed entries are returned.
+func (c *Cache) Prune(maxAge time.Duration, maxSize int64) ([]CacheEntry, error) {
+	entries, err := c.List()
+	if err != nil {
+		return nil, err
+	}
+	total := int64(0)
+	for _, e := range entries {
+		total += e.Size
+	}
+	removed := []CacheEntry{}
+	for _, e := range entries {
+		tooOld := maxAge > 0 && time.Since(e.ModTime) > maxAge
+		tooLarge := maxSize > 0 && total > maxSize
+		if !tooOld && !tooLarge {
+			continue
+		}
+		err := os.Remove(e.Path)
+		if err != nil {
+			return removed, err
+		}
+		total -= e.Size
+		removed = append(removed, e)
+	}
+	return removed, nil
+}
+
+// Clear removes all the entries of the cache.
+func (c *Cache) Clear() error {
+	for algo := range algos {
+		err := os.RemoveAll(filepath.Join(c.dir, algo))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createTempFile creates a new file in dir whose name starts with prefix.
+// Unlike os.CreateTemp, it is given the permissions of the files created
+// by os.Create, so that files restored from the cache get the same mode
+// as downloaded ones.
+func createTempFile(dir string, prefix string) (*os.File, error) {
+	for {
+		name := filepath.Join(dir, prefix+strconv.FormatUint(uint64(rand.Uint32()), 10))
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if os.IsExist(err) {
+			continue
+		}
+		return f, err
+	}
+}
+
+// copyToTempFile copies the file at path to a new temporary file in dir
+// and returns its path.
+func copyToTempFile(path string, dir string) (string, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	dst, err := createTempFile(dir, ".grabit-")
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(dst, src)
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dst.Name())
+		return "", err
+	}
+	return dst.Name(), nil
+}
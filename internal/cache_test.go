@@ -0,0 +1,155 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cisco-open/grabit/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultCacheDir(t *testing.T) {
+	t.Setenv(GRABIT_CACHE_DIR_ENV_VAR, "/tmp/grabit-cache")
+	dir, err := DefaultCacheDir()
+	assert.Nil(t, err)
+	assert.Equal(t, "/tmp/grabit-cache", dir)
+
+	t.Setenv(GRABIT_CACHE_DIR_ENV_VAR, "")
+	t.Setenv("XDG_CACHE_HOME", "/tmp/xdg")
+	dir, err = DefaultCacheDir()
+	assert.Nil(t, err)
+	assert.Equal(t, "/tmp/xdg/grabit", dir)
+}
+
+func TestCachePutGet(t *testing.T) {
+	content := `abcdef`
+	integrity := test.GetSha256Integrity(content)
+	cache, err := NewCache(test.TmpDir(t))
+	require.Nil(t, err)
+	dest := filepath.Join(test.TmpDir(t), "test.html")
+
+	ok, err := cache.Get(integrity, dest)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	err = cache.Put(test.TmpFile(t, content), integrity)
+	assert.Nil(t, err)
+	entries, err := cache.List()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, integrity, entries[0].Integrity)
+	assert.Equal(t, int64(len(content)), entries[0].Size)
+
+	ok, err = cache.Get(integrity, dest)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	test.AssertFileContains(t, dest, content)
+}
+
+func TestCacheCorruptedEntry(t *testing.T) {
+	content := `abcdef`
+	integrity := test.GetSha256Integrity(content)
+	cache, err := NewCache(test.TmpDir(t))
+	require.Nil(t, err)
+	err = cache.Put(test.TmpFile(t, content), integrity)
+	require.Nil(t, err)
+	entries, err := cache.List()
+	require.Nil(t, err)
+	err = os.WriteFile(entries[0].Path, []byte("corrupted"), 0644)
+	require.Nil(t, err)
+
+	corrupted, err := cache.Verify()
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, len(corrupted))
+
+	ok, err := cache.Get(integrity, filepath.Join(test.TmpDir(t), "test.html"))
+	assert.NotNil(t, err)
+	assert.False(t, ok)
+	assert.NoFileExists(t, entries[0].Path)
+}
+
+func TestCachePruneAndClear(t *testing.T) {
+	cache, err := NewCache(test.TmpDir(t))
+	require.Nil(t, err)
+	for i, content := range []string{"a", "bb", "ccc"} {
+		integrity := test.GetSha256Integrity(content)
+		err = cache.Put(test.TmpFile(t, content), integrity)
+		require.Nil(t, err)
+		entry, err := cache.entryPath(integrity)
+		require.Nil(t, err)
+		modTime := time.Now().Add(-time.Duration(3-i) * time.Hour)
+		err = os.Chtimes(entry, modTime, modTime)
+		require.Nil(t, err)
+	}
+
+	removed, err := cache.Prune(150*time.Minute, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(removed))
+	assert.Equal(t, test.GetSha256Integrity("a"), removed[0].Integrity)
+
+	removed, err = cache.Prune(0, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(removed))
+	assert.Equal(t, test.GetSha256Integrity("bb"), removed[0].Integrity)
+
+	err = cache.Clear()
+	assert.Nil(t, err)
+	entries, err := cache.List()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(entries))
+}
+
+func TestResourceDownloadWithLocalCache(t *testing.T) {
+	content := `abcdef`
+	contentIntegrity := test.GetSha256Integrity(content)
+	port, server := test.TestHttpHandlerWithServer(content, t)
+	resource := Resource{Urls: []string{fmt.Sprintf("http://localhost:%d/test.html", port)}, Integrity: contentIntegrity, Tags: []string{}, Filename: ""}
+	cache, err := NewCache(test.TmpDir(t))
+	require.Nil(t, err)
+	opts := DownloadOptions{Cache: cache}
+
+	// First download populates the cache.
+	err = resource.Download(test.TmpDir(t), 0644, context.Background(), opts)
+	require.Nil(t, err)
+	entries, err := cache.List()
+	require.Nil(t, err)
+	assert.Equal(t, 1, len(entries))
+
+	// Second download is served from the cache.
+	server.Close()
+	outputDir := test.TmpDir(t)
+	err = resource.Download(outputDir, 0644, context.Background(), opts)
+	require.Nil(t, err)
+	test.AssertFileContains(t, filepath.Join(outputDir, "test.html"), content)
+}
+
+func TestResourceDownloadWithLocalCacheMode(t *testing.T) {
+	// Whether the cache is hit does not change the mode of the file.
+	content := `abcdef`
+	port, server := test.TestHttpHandlerWithServer(content, t)
+	resource := Resource{Urls: []string{fmt.Sprintf("http://localhost:%d/test.html", port)}, Integrity: test.GetSha256Integrity(content)}
+	cache, err := NewCache(test.TmpDir(t))
+	require.Nil(t, err)
+	opts := DownloadOptions{Cache: cache}
+	downloadDir := test.TmpDir(t)
+	err = resource.Download(downloadDir, NoFileMode, context.Background(), opts)
+	require.Nil(t, err)
+	downloaded, err := os.Stat(filepath.Join(downloadDir, "test.html"))
+	require.Nil(t, err)
+
+	server.Close()
+	cachedDir := test.TmpDir(t)
+	err = resource.Download(cachedDir, NoFileMode, context.Background(), opts)
+	require.Nil(t, err)
+	cached, err := os.Stat(filepath.Join(cachedDir, "test.html"))
+	require.Nil(t, err)
+	assert.Equal(t, downloaded.Mode(), cached.Mode())
+}
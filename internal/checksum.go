@@ -0,0 +1,128 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/carlmjohnson/requests"
+)
+
+// algoFromDigestSize maps the size of hex digests found in checksum files
+// that do not name their algorithm (e.g. SHA256SUMS) to the algorithm.
+var algoFromDigestSize = map[int]string{
+	40:  "sha1",
+	64:  "sha256",
+	96:  "sha384",
+	128: "sha512",
+}
+
+var (
+	// GNU coreutils format: '<hex digest> <' ' or '*'><file name>'.
+	gnuChecksumLine = regexp.MustCompile(`^\\?([0-9a-fA-F]+) [ *]?(.+)$`)
+	// BSD format: '<ALGO> (<file name>) = <hex digest>'.
+	bsdChecksumLine = regexp.MustCompile(`^([A-Za-z0-9-]+) ?\((.+)\) ?= ?([0-9a-fA-F]+)$`)
+	// Single hash files only hold the digest of the file they are named after.
+	singleChecksum = regexp.MustCompile(`^([0-9a-fA-F]+)$`)
+)
+
+// fetchChecksum downloads the checksum file at checksumUrl and returns the
+// integrity of the first of the given file names it lists.
+func fetchChecksum(checksumUrl string, fileNames []string, ctx context.Context) (string, error) {
+	var content string
+	err := requests.
+		URL(checksumUrl).
+		Transport(noCompressionTransport).
+		ToString(&content).
+		Fetch(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get checksum file '%s': %w", checksumUrl, err)
+	}
+	integrity, err := parseChecksumFile(content, fileNames)
+	if err != nil {
+		return "", fmt.Errorf("invalid checksum file '%s': %w", checksumUrl, err)
+	}
+	return integrity, nil
+}
+
+// parseChecksumFile returns the integrity of the first of the given file
+// names listed in content, which can use the GNU coreutils or the BSD
+// format, or hold a single digest.
+func parseChecksumFile(content string, fileNames []string) (string, error) {
+	lines := []string{}
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 1 && singleChecksum.MatchString(lines[0]) {
+		return checksumToIntegrity("", lines[0])
+	}
+	for _, name := range fileNames {
+		for _, line := range lines {
+			algo, digest, entry, ok := parseChecksumLine(line)
+			if ok && entry == name {
+				return checksumToIntegrity(algo, digest)
+			}
+		}
+	}
+	return "", fmt.Errorf("no checksum found for '%s'", strings.Join(fileNames, "' or '"))
+}
+
+// parseChecksumLine returns the algorithm (empty if unknown), digest and
+// base file name of a checksum file line.
+func parseChecksumLine(line string) (string, string, string, bool) {
+	if m := bsdChecksumLine.FindStringSubmatch(line); m != nil {
+		return m[1], m[3], path.Base(m[2]), true
+	}
+	if m := gnuChecksumLine.FindStringSubmatch(line); m != nil {
+		name := m[2]
+		if strings.HasPrefix(line, `\`) {
+			// Names with special characters are escaped.
+			name = strings.NewReplacer(`\\`, `\`, `\n`, "\n", `\r`, "\r").Replace(name)
+		}
+		return "", m[1], path.Base(name), true
+	}
+	return "", "", "", false
+}
+
+// checksumToIntegrity returns the SRI string of a hex digest. The
+// algorithm is deduced from the digest size when not given.
+func checksumToIntegrity(algo string, digest string) (string, error) {
+	if algo == "" {
+		var ok bool
+		algo, ok = algoFromDigestSize[len(digest)]
+		if !ok {
+			return "", fmt.Errorf("cannot deduce the algorithm of digest '%s'", digest)
+		}
+	}
+	// BSD style files use names such as 'SHA256' or 'SHA2-256'.
+	algo = strings.ReplaceAll(strings.ToLower(algo), "sha2-", "sha")
+	decoded, err := hex.DecodeString(digest)
+	if err != nil {
+		return "", fmt.Errorf("invalid digest '%s': %w", digest, err)
+	}
+	return ParseIntegrity(integrityFromDigest(algo, decoded))
+}
+
+// checksumFileNames returns the names the resource can be listed under in
+// a checksum file: the base name of its url and its Filename, if set.
+func (l *Resource) checksumFileNames() []string {
+	names := []string{}
+	if parsed, err := url.Parse(l.Urls[0]); err == nil {
+		names = append(names, path.Base(parsed.Path))
+	}
+	if l.Filename != "" && !slices.Contains(names, l.Filename) {
+		names = append(names, l.Filename)
+	}
+	return names
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/cisco-open/grabit/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testSha1Hex   = "1f8ac10f23c5b5bc1167bda84b833e5c057a77d2"
+	testSha256Hex = "bef57ec7f53a6d40beb640a780a639c83bc29ac8a9816f1fc6c5c6dcd93c4721"
+	testSha512Hex = "e32ef19623e8ed9d267f657a81944b3d07adbb768518068e88435745564e8d4150a0a703be2a7d88b61e3d390c2bb97e2d4c311fdc69d6b1267f05f59aa920e7"
+)
+
+func TestParseChecksumFile(t *testing.T) {
+	sha256Integrity, _ := ParseIntegrity("sha256:" + testSha256Hex)
+	sha512Integrity, _ := ParseIntegrity("sha512:" + testSha512Hex)
+	sha1Integrity, _ := ParseIntegrity("sha1:" + testSha1Hex)
+	tests := []struct {
+		name          string
+		content       string
+		integrity     string
+		errorContains string
+	}{
+		{"gnu text", fmt.Sprintf("0000000000000000000000000000000000000000000000000000000000000000  other.tar.gz\n%s  test.tar.gz\n", testSha256Hex), sha256Integrity, ""},
+		{"gnu binary", fmt.Sprintf("%s *test.tar.gz\n", testSha512Hex), sha512Integrity, ""},
+		{"gnu path", fmt.Sprintf("%s  ./dist/test.tar.gz\n", testSha1Hex), sha1Integrity, ""},
+		{"bsd", fmt.Sprintf("SHA256 (test.tar.gz) = %s\n", testSha256Hex), sha256Integrity, ""},
+		{"bsd sha2", fmt.Sprintf("SHA2-512 (test.tar.gz) = %s\n", testSha512Hex), sha512Integrity, ""},
+		{"single", testSha256Hex + "\n", sha256Integrity, ""},
+		{"comments", fmt.Sprintf("# checksums\n\n%s  test.tar.gz\n", testSha256Hex), sha256Integrity, ""},
+		{"missing", fmt.Sprintf("%s  other.tar.gz\n", testSha256Hex), "", "no checksum found for 'test.tar.gz'"},
+		{"unknown size", "abcdef  test.tar.gz\n", "", "cannot deduce the algorithm"},
+		{"unknown algo", fmt.Sprintf("MD5 (test.tar.gz) = %s\n", testSha256Hex), "", "unknown hash algorithm"},
+	}
+	for _, data := range tests {
+		t.Run(data.name, func(t *testing.T) {
+			integrity, err := parseChecksumFile(data.content, []string{"test.tar.gz"})
+			if data.errorContains == "" {
+				assert.Nil(t, err)
+				assert.Equal(t, data.integrity, integrity)
+			} else {
+				assert.ErrorContains(t, err, data.errorContains)
+			}
+		})
+	}
+}
+
+func TestNewResourceWithChecksumUrl(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		switch r.URL.Path {
+		case "/SHA256SUMS":
+			_, err = fmt.Fprintf(w, "%s  test.tar.gz\n%s  bad.tar.gz\n", testSha256Hex, testSha256Hex)
+		case "/test.tar.gz":
+			_, err = w.Write([]byte("abcdef"))
+		default:
+			_, err = w.Write([]byte("tampered"))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	port, server := test.HttpHandler(handler)
+	t.Cleanup(server.Close)
+	checksumUrl := fmt.Sprintf("http://localhost:%d/SHA256SUMS", port)
+
+	// The checksum algorithm does not need to match the resource one.
+	resource, err := NewResource(Resource{Urls: []string{fmt.Sprintf("http://localhost:%d/test.tar.gz", port)}}, AddOptions{Algo: "sha512", ChecksumUrl: checksumUrl})
+	require.Nil(t, err)
+	sha512Integrity, err := ParseIntegrity("sha512:" + testSha512Hex)
+	require.Nil(t, err)
+	assert.Equal(t, sha512Integrity, resource.Integrity)
+
+	_, err = NewResource(Resource{Urls: []string{fmt.Sprintf("http://localhost:%d/bad.tar.gz", port)}}, AddOptions{Algo: "sha256", ChecksumUrl: checksumUrl})
+	assert.ErrorContains(t, err, "does not match its checksum")
+
+	_, err = NewResource(Resource{Urls: []string{fmt.Sprintf("http://localhost:%d/other.tar.gz", port)}}, AddOptions{Algo: "sha256", ChecksumUrl: checksumUrl})
+	assert.ErrorContains(t, err, "no checksum found for 'other.tar.gz'")
+
+	// The file name is looked up too.
+	resource, err = NewResource(Resource{Urls: []string{fmt.Sprintf("http://localhost:%d/test.tar.gz?download=1", port)}, Filename: "bad.tar.gz"}, AddOptions{Algo: "sha256", ChecksumUrl: checksumUrl})
+	require.Nil(t, err)
+	assert.Equal(t, test.GetSha256Integrity("abcdef"), resource.Integrity)
+}
@@ -0,0 +1,372 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// keptNames returns the top-level names in a download directory that
+// belong to the resource: its files, the partial downloads of its urls
+// and its extracted content.
+func (l *Resource) keptNames() ([]string, error) {
+	names := []string{}
+	for _, name := range l.localNames() {
+		// Files named with a directory keep it whole.
+		names = append(names, topName(name))
+	}
+	for _, u := range l.Urls {
+		temp := filepath.Base(tempFileName(u, ""))
+		names = append(names, temp, validatorFileName(temp))
+	}
+	if !l.Extract {
+		return names, nil
+	}
+	if l.ExtractDir != "" {
+		if !isSubdirectory(l.ExtractDir) {
+			return nil, fmt.Errorf("extraction directory '%s' of '%s' is not below the download directory", l.ExtractDir, l.Urls[0])
+		}
+		return append(names, topName(l.ExtractDir)), nil
+	}
+	if len(l.Manifest) == 0 {
+		// The extracted files are mixed with the other ones.
+		return nil, fmt.Errorf("cannot tell the files extracted from '%s' from stale ones: it needs an extraction directory or a manifest", l.Urls[0])
+	}
+	for name := range l.Manifest {
+		names = append(names, topName(name))
+	}
+	return names, nil
+}
+
+// topName returns the first element of the relative path name.
+func topName(name string) string {
+	top, _, _ := strings.Cut(filepath.ToSlash(filepath.Clean(name)), "/")
+	return top
+}
+
+// CleanOptions are the options of Lock.Clean.
+type CleanOptions struct {
+	// DryRun only lists the entries that would be removed.
+	DryRun bool
+	// Force cleans directories that hold more than downloaded files: the
+	// directory of the lock file and the ones under version control.
+	Force bool
+}
+
+// ErrNotDownloadDir is returned when cleaning a directory that holds more
+// than downloaded files.
+var ErrNotDownloadDir = errors.New("not a download directory")
+
+// vcsDirs are the metadata directories of version control systems.
+var vcsDirs = []string{".git", ".hg", ".svn", ".bzr", ".jj", "_darcs"}
+
+// checkDownloadDir returns an error if dir is the directory of the lock
+// file or is under version control: all their content would be stale.
+func (l *Lock) checkDownloadDir(dir string) error {
+	for _, name := range vcsDirs {
+		if _, err := os.Lstat(filepath.Join(dir, name)); err == nil {
+			return fmt.Errorf("%w: '%s' is under version control", ErrNotDownloadDir, dir)
+		}
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	absLockDir, err := filepath.Abs(filepath.Dir(l.path))
+	if err != nil {
+		return err
+	}
+	if absDir == absLockDir {
+		return fmt.Errorf("%w: '%s' holds the lock file '%s'", ErrNotDownloadDir, dir, l.path)
+	}
+	return nil
+}
+
+// Clean removes the entries of dir that do not belong to the resources of
+// this lock file selected by filter, including the orphaned partial
+// downloads, and returns their paths. The lock file and its signature
+// are never removed. Unless forced, dir cannot be the directory of the
+// lock file or be under version control.
+func (l *Lock) Clean(dir string, filter ResourceFilter, opts CleanOptions) ([]string, error) {
+	if stat, err := os.Stat(dir); err != nil || !stat.IsDir() {
+		return nil, fmt.Errorf("'%s' is not a directory", dir)
+	}
+	if !opts.Force {
+		err := l.checkDownloadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+	}
+	kept := map[string]bool{}
+	for _, r := range l.Filter(filter) {
+		names, err := r.keptNames()
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			kept[name] = true
+		}
+	}
+	protected := []string{}
+	for _, p := range []string{l.path, LockSignaturePath(l.path)} {
+		abs, err := filepath.Abs(p)
+		if err == nil {
+			protected = append(protected, abs)
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	removed := []string{}
+	errs := []error{}
+	for _, e := range entries {
+		if kept[e.Name()] {
+			continue
+		}
+		p := filepath.Join(dir, e.Name())
+		if abs, err := filepath.Abs(p); err == nil && slices.Contains(protected, abs) {
+			continue
+		}
+		if opts.DryRun {
+			removed = append(removed, p)
+			continue
+		}
+		log.Debug().Msgf("Removing '%s'", p)
+		err := os.RemoveAll(p)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cannot remove '%s': %w", p, err))
+			continue
+		}
+		removed = append(removed, p)
+	}
+	return removed, errors.Join(errs...)
+}
+
+// RemoveFiles removes from dir the files of the resources of this lock
+// file selected by filter, as Resource.RemoveFiles does, and returns
+// their paths. The files that also belong to the other resources are
+// kept.
+func (l *Lock) RemoveFiles(dir string, filter ResourceFilter) ([]string, error) {
+	kept := []string{}
+	for _, r := range l.conf.Resource {
+		if filter.Matches(&r) {
+			continue
+		}
+		owned, err := r.ownedFiles(dir)
+		if err != nil {
+			return nil, &ResourceError{Url: r.Urls[0], Err: err}
+		}
+		kept = append(kept, owned...)
+	}
+	removed := []string{}
+	errs := []error{}
+	for _, r := range l.Filter(filter) {
+		files, err := r.removeFiles(dir, kept)
+		removed = append(removed, files...)
+		if err != nil {
+			errs = append(errs, &ResourceError{Url: r.Urls[0], Err: err})
+		}
+	}
+	return removed, errors.Join(errs...)
+}
+
+// RemoveFiles removes the files of the resource from dir: its downloaded
+// files, the partial downloads of its urls and its extracted content, and
+// returns their paths. The extracted files are found in its manifest or,
+// failing that, its downloaded archive. Otherwise, its whole extraction
+// directory is removed: use Lock.RemoveFiles to keep the files of the
+// other resources extracted there.
+func (l *Resource) RemoveFiles(dir string) ([]string, error) {
+	return l.removeFiles(dir, nil)
+}
+
+// removeFiles removes the files of the resource from dir, except the kept
+// ones. Directories holding kept files are not removed.
+func (l *Resource) removeFiles(dir string, kept []string) ([]string, error) {
+	removed := []string{}
+	errs := []error{}
+	remove := func(p string) {
+		// Hand-edited lock files must not remove dir or anything above it.
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || !isSubdirectory(rel) {
+			errs = append(errs, fmt.Errorf("cannot remove '%s': it is not below '%s'", p, dir))
+			return
+		}
+		info, err := os.Lstat(p)
+		if os.IsNotExist(err) {
+			return
+		}
+		for _, k := range kept {
+			// Extraction directories shared with another resource.
+			if isBelow(k, p) || (k == p && err == nil && info.IsDir()) {
+				errs = append(errs, fmt.Errorf("cannot remove '%s': it holds '%s', which belongs to another resource", p, k))
+				return
+			}
+			if k == p || isBelow(p, k) {
+				log.Debug().Msgf("Keeping '%s', which belongs to another resource", p)
+				return
+			}
+		}
+		log.Debug().Msgf("Removing '%s'", p)
+		err = os.RemoveAll(p)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cannot remove '%s': %w", p, err))
+			return
+		}
+		removed = append(removed, p)
+	}
+	if l.Extract {
+		extracted, err := l.extractedFiles(dir)
+		if err != nil {
+			return nil, err
+		}
+		if len(extracted) == 0 {
+			log.Warn().Msgf("Cannot find the files extracted from '%s': it has no manifest and its archive is missing from '%s'", l.Urls[0], dir)
+		}
+		for _, p := range extracted {
+			remove(p)
+		}
+		removeEmptyParents(extracted, dir)
+	}
+	for _, name := range l.localNames() {
+		remove(filepath.Join(dir, name))
+	}
+	for _, u := range l.Urls {
+		temp := tempFileName(u, dir)
+		remove(temp)
+		remove(validatorFileName(temp))
+	}
+	return removed, errors.Join(errs...)
+}
+
+// ownedFiles returns the paths of the files of the resource in dir, as
+// removed by RemoveFiles.
+func (l *Resource) ownedFiles(dir string) ([]string, error) {
+	files := []string{}
+	if l.Extract {
+		extracted, err := l.extractedFiles(dir)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, extracted...)
+	}
+	for _, name := range l.localNames() {
+		files = append(files, filepath.Join(dir, name))
+	}
+	for _, u := range l.Urls {
+		temp := tempFileName(u, dir)
+		files = append(files, temp, validatorFileName(temp))
+	}
+	return files, nil
+}
+
+// isBelow returns true if the path p is strictly below the directory dir.
+func isBelow(p string, dir string) bool {
+	rel, err := filepath.Rel(dir, p)
+	return err == nil && isSubdirectory(rel)
+}
+
+// hasExtractDir returns true if the resource is extracted into a
+// directory of its own rather than into the download directory itself.
+func (l *Resource) hasExtractDir() bool {
+	return l.ExtractDir != "" && filepath.Clean(l.ExtractDir) != "."
+}
+
+// extractedFiles returns the paths of the content extracted from the
+// resource archive into dir. It is empty if the resource has neither a
+// manifest nor an archive in dir and no extraction directory.
+func (l *Resource) extractedFiles(dir string) ([]string, error) {
+	extractDir := dir
+	if l.hasExtractDir() {
+		var err error
+		extractDir, err = l.extractDir(dir)
+		if err != nil {
+			return nil, err
+		}
+	}
+	names := []string{}
+	for name := range l.Manifest {
+		names = append(names, name)
+	}
+	for _, name := range l.localNames() {
+		archive := filepath.Join(dir, name)
+		if _, err := os.Stat(archive); err != nil {
+			continue
+		}
+		// The archive also lists the links, which are not in the manifest.
+		entries, err := l.archiveEntries(archive)
+		if err != nil && !l.hasExtractDir() {
+			return nil, fmt.Errorf("cannot list the files extracted from '%s': %w", l.Urls[0], err)
+		}
+		if err != nil {
+			log.Debug().Msgf("Cannot list the files extracted from '%s': %v", l.Urls[0], err)
+		} else {
+			names = entries
+		}
+		break
+	}
+	if len(names) == 0 && l.hasExtractDir() {
+		// The whole extraction directory is the extracted content.
+		return []string{extractDir}, nil
+	}
+	files := []string{}
+	for _, name := range names {
+		files = append(files, filepath.Join(extractDir, filepath.FromSlash(name)))
+	}
+	slices.Sort(files)
+	return files, nil
+}
+
+// archiveEntries extracts the archive file as the resource would be and
+// returns the slash-separated paths of the files and links it holds.
+func (l *Resource) archiveEntries(archive string) ([]string, error) {
+	dir, err := os.MkdirTemp("", "grabit-entries-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	err = extractArchive(archive, dir, l.StripComponents)
+	if err != nil {
+		return nil, err
+	}
+	entries := []string{}
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, filepath.ToSlash(rel))
+		return nil
+	})
+	return entries, err
+}
+
+// removeEmptyParents removes the directories of dir left empty by the
+// removal of the given files.
+func removeEmptyParents(files []string, dir string) {
+	for _, f := range files {
+		rel, err := filepath.Rel(dir, f)
+		if err != nil {
+			continue
+		}
+		for parent := filepath.Dir(rel); parent != "." && filepath.IsLocal(parent); parent = filepath.Dir(parent) {
+			// Non-empty directories are not removed.
+			if os.Remove(filepath.Join(dir, parent)) != nil {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,269 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cisco-open/grabit/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockClean(t *testing.T) {
+	dir := test.TmpDir(t)
+	lockPath := filepath.Join(dir, "grabit.lock")
+	err := os.WriteFile(lockPath, []byte(`
+	[[Resource]]
+	Urls = ['http://localhost:123456/a.html']
+	Integrity = 'sha256-a'
+	Tags = ['tag']
+
+	[[Resource]]
+	Urls = ['http://localhost:123456/b.tar.gz']
+	Integrity = 'sha256-b'
+	Extract = true
+	ExtractDir = 'b/1.0'
+
+	[[Resource]]
+	Urls = ['http://localhost:123456/c.zip']
+	Integrity = 'sha256-c'
+	Filename = 'c.zip'
+	Extract = true
+	[Resource.Manifest]
+	'c/file' = 'sha256-c'
+`), 0644)
+	require.Nil(t, err)
+	lock, err := NewLock(lockPath, false)
+	require.Nil(t, err)
+	// The lock file is in the download directory.
+	partial := tempFileName("http://localhost:123456/a.html", dir)
+	orphan := tempFileName("http://localhost:123456/deleted.html", dir)
+	for _, p := range []string{"a.html", "b.tar.gz", "b/1.0/file", "c.zip", "c/file", "deleted.html", "old/file", partial, validatorFileName(partial), orphan, validatorFileName(orphan)} {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(dir, p)
+		}
+		require.Nil(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.Nil(t, os.WriteFile(p, []byte("abc"), 0644))
+	}
+	stale := []string{filepath.Join(dir, "deleted.html"), filepath.Join(dir, "old"), orphan, validatorFileName(orphan)}
+
+	removed, err := lock.Clean(dir, ResourceFilter{}, CleanOptions{DryRun: true, Force: true})
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, stale, removed)
+	for _, p := range stale {
+		_, err := os.Stat(p)
+		assert.Nil(t, err)
+	}
+
+	removed, err = lock.Clean(dir, ResourceFilter{}, CleanOptions{Force: true})
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, stale, removed)
+	for _, p := range stale {
+		_, err := os.Stat(p)
+		assert.True(t, os.IsNotExist(err))
+	}
+	for _, p := range []string{"grabit.lock", "a.html", "b/1.0/file", "c/file", filepath.Base(partial)} {
+		assert.FileExists(t, filepath.Join(dir, p))
+	}
+
+	removed, err = lock.Clean(dir, NewTagFilter(nil, []string{"tag"}), CleanOptions{Force: true})
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{filepath.Join(dir, "a.html"), partial, validatorFileName(partial)}, removed)
+}
+
+func TestLockCleanFilenameInDirectory(t *testing.T) {
+	lockPath := test.TmpFile(t, `
+	[[Resource]]
+	Urls = ['http://localhost:123456/f.txt']
+	Integrity = 'sha256-f'
+	Filename = 'bin/f.txt'
+`)
+	lock, err := NewLock(lockPath, false)
+	require.Nil(t, err)
+	dir := test.TmpDir(t)
+	file := filepath.Join(dir, "bin", "f.txt")
+	require.Nil(t, os.MkdirAll(filepath.Dir(file), 0755))
+	require.Nil(t, os.WriteFile(file, []byte("abc"), 0644))
+	removed, err := lock.Clean(dir, ResourceFilter{}, CleanOptions{})
+	assert.Nil(t, err)
+	assert.Empty(t, removed)
+	assert.FileExists(t, file)
+}
+
+func TestLockCleanNotDownloadDir(t *testing.T) {
+	dir := test.TmpDir(t)
+	lockPath := filepath.Join(dir, "grabit.lock")
+	require.Nil(t, os.WriteFile(lockPath, []byte(""), 0644))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "README"), []byte("abc"), 0644))
+	lock, err := NewLock(lockPath, false)
+	require.Nil(t, err)
+	_, err = lock.Clean(dir, ResourceFilter{}, CleanOptions{})
+	assert.ErrorIs(t, err, ErrNotDownloadDir)
+	assert.ErrorContains(t, err, "holds the lock file")
+
+	other := test.TmpDir(t)
+	require.Nil(t, os.Mkdir(filepath.Join(other, ".git"), 0755))
+	_, err = lock.Clean(other, ResourceFilter{}, CleanOptions{DryRun: true})
+	assert.ErrorIs(t, err, ErrNotDownloadDir)
+	assert.ErrorContains(t, err, "is under version control")
+	assert.DirExists(t, filepath.Join(other, ".git"))
+
+	removed, err := lock.Clean(dir, ResourceFilter{}, CleanOptions{Force: true})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "README")}, removed)
+	assert.FileExists(t, lockPath)
+}
+
+func TestLockCleanUnknownExtractedFiles(t *testing.T) {
+	lockPath := test.TmpFile(t, `
+	[[Resource]]
+	Urls = ['http://localhost:123456/a.tar.gz']
+	Integrity = 'sha256-a'
+	Extract = true
+`)
+	lock, err := NewLock(lockPath, false)
+	require.Nil(t, err)
+	_, err = lock.Clean(test.TmpDir(t), ResourceFilter{}, CleanOptions{DryRun: true})
+	assert.ErrorContains(t, err, "cannot tell the files extracted from")
+}
+
+func TestLockCleanExtractDirNotBelow(t *testing.T) {
+	// Such lock files can only be edited by hand: the download directory
+	// itself must never be kept as an extraction directory.
+	lockPath := test.TmpFile(t, `
+	[[Resource]]
+	Urls = ['http://localhost:123456/a.tar.gz']
+	Integrity = 'sha256-a'
+	Extract = true
+	ExtractDir = 'a/..'
+`)
+	lock, err := NewLock(lockPath, false)
+	require.Nil(t, err)
+	_, err = lock.Clean(test.TmpDir(t), ResourceFilter{}, CleanOptions{DryRun: true})
+	assert.ErrorContains(t, err, "is not below the download directory")
+}
+
+func TestResourceRemoveFiles(t *testing.T) {
+	tests := []struct {
+		name          string
+		resource      Resource
+		removeArchive bool
+	}{
+		{"archive", Resource{Urls: []string{"http://localhost:123456/pkg.tar.gz"}, Integrity: "sha256-a", Extract: true}, false},
+		{"manifest", Resource{Urls: []string{"http://localhost:123456/pkg.tar.gz"}, Integrity: "sha256-a", Extract: true,
+			Manifest: map[string]string{"pkg-1.0/README": "sha256-r", "pkg-1.0/bin/tool": "sha256-t", "pkg-1.0/bin/link": "sha256-l"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := test.TmpDir(t)
+			archive := filepath.Join(dir, "pkg.tar.gz")
+			require.Nil(t, os.WriteFile(archive, []byte(test.Archive(t, FormatTarGz, testArchiveEntries)), 0644))
+			require.Nil(t, extractArchive(archive, dir, 0))
+			require.Nil(t, os.WriteFile(filepath.Join(dir, "other"), []byte("abc"), 0644))
+			if tt.removeArchive {
+				// The manifest is used when the archive is gone.
+				require.Nil(t, os.Remove(archive))
+			}
+
+			removed, err := tt.resource.RemoveFiles(dir)
+			assert.Nil(t, err)
+			assert.Contains(t, removed, filepath.Join(dir, "pkg-1.0", "bin", "tool"))
+			assert.NoDirExists(t, filepath.Join(dir, "pkg-1.0"))
+			assert.NoFileExists(t, archive)
+			assert.FileExists(t, filepath.Join(dir, "other"))
+		})
+	}
+}
+
+func TestResourceRemoveFilesExtractDir(t *testing.T) {
+	dir := test.TmpDir(t)
+	r := Resource{Urls: []string{"http://localhost:123456/pkg.zip"}, Integrity: "sha256-a", Filename: "pkg.zip", Extract: true, ExtractDir: "pkg/1.0"}
+	partial := tempFileName(r.Urls[0], dir)
+	for _, p := range []string{filepath.Join(dir, "pkg.zip"), filepath.Join(dir, "pkg", "1.0", "file"), filepath.Join(dir, "pkg", "2.0", "file"), partial} {
+		require.Nil(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.Nil(t, os.WriteFile(p, []byte("abc"), 0644))
+	}
+	removed, err := r.RemoveFiles(dir)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{filepath.Join(dir, "pkg", "1.0"), filepath.Join(dir, "pkg.zip"), partial}, removed)
+	assert.FileExists(t, filepath.Join(dir, "pkg", "2.0", "file"))
+}
+
+func TestResourceRemoveFilesExtractDirIsDir(t *testing.T) {
+	// An extraction directory naming the download directory is handled as
+	// an empty one: only the extracted files are removed.
+	for _, extractDir := range []string{".", "pkg/.."} {
+		t.Run(extractDir, func(t *testing.T) {
+			dir := test.TmpDir(t)
+			r := Resource{Urls: []string{"http://localhost:123456/pkg.zip"}, Integrity: "sha256-a", Filename: "pkg.zip", Extract: true, ExtractDir: extractDir,
+				Manifest: map[string]string{"pkg/file": "sha256-f"}}
+			for _, p := range []string{filepath.Join(dir, "pkg", "file"), filepath.Join(dir, "other")} {
+				require.Nil(t, os.MkdirAll(filepath.Dir(p), 0755))
+				require.Nil(t, os.WriteFile(p, []byte("abc"), 0644))
+			}
+			removed, err := r.RemoveFiles(dir)
+			assert.Nil(t, err)
+			assert.Equal(t, []string{filepath.Join(dir, "pkg", "file")}, removed)
+			assert.NoDirExists(t, filepath.Join(dir, "pkg"))
+			assert.FileExists(t, filepath.Join(dir, "other"))
+		})
+	}
+}
+
+func TestResourceRemoveFilesOutsideDir(t *testing.T) {
+	parent := test.TmpDir(t)
+	dir := filepath.Join(parent, "dir")
+	require.Nil(t, os.Mkdir(dir, 0755))
+	outside := filepath.Join(parent, "outside")
+	require.Nil(t, os.WriteFile(outside, []byte("abc"), 0644))
+	r := Resource{Urls: []string{"http://localhost:123456/pkg.zip"}, Integrity: "sha256-a", Extract: true,
+		Manifest: map[string]string{"../outside": "sha256-o", ".": "sha256-d"}}
+	_, err := r.RemoveFiles(dir)
+	assert.ErrorContains(t, err, "is not below")
+	assert.FileExists(t, outside)
+	assert.DirExists(t, dir)
+}
+
+func TestLockRemoveFilesSharedExtractDir(t *testing.T) {
+	dir := test.TmpDir(t)
+	lockPath := filepath.Join(test.TmpDir(t), "grabit.lock")
+	require.Nil(t, os.WriteFile(lockPath, []byte(`
+	[[Resource]]
+	Urls = ['http://localhost:123456/one.tar.gz']
+	Integrity = 'sha256-a'
+	Extract = true
+	ExtractDir = 'tools'
+
+	[[Resource]]
+	Urls = ['http://localhost:123456/two.tar.gz']
+	Integrity = 'sha256-b'
+	Extract = true
+	ExtractDir = 'tools'
+`), 0644))
+	lock, err := NewLock(lockPath, false)
+	require.Nil(t, err)
+	for name, entry := range map[string]string{"one.tar.gz": "one.txt", "two.tar.gz": "two.txt"} {
+		archive := filepath.Join(dir, name)
+		require.Nil(t, os.WriteFile(archive, []byte(test.Archive(t, FormatTarGz, []test.ArchiveEntry{{Name: entry, Content: entry}})), 0644))
+		require.Nil(t, extractArchive(archive, filepath.Join(dir, "tools"), 0))
+	}
+
+	// Only the files of the archive are removed.
+	removed, err := lock.RemoveFiles(dir, ResourceFilter{Urls: []string{"http://localhost:123456/one.tar.gz"}})
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{filepath.Join(dir, "tools", "one.txt"), filepath.Join(dir, "one.tar.gz")}, removed)
+	assert.FileExists(t, filepath.Join(dir, "tools", "two.txt"))
+
+	// Without its archive nor a manifest, the files of a resource cannot
+	// be told from the ones of the others.
+	require.Nil(t, os.Remove(filepath.Join(dir, "two.tar.gz")))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "tools", "one.txt"), []byte("one.txt"), 0644))
+	_, err = lock.RemoveFiles(dir, ResourceFilter{Urls: []string{"http://localhost:123456/two.tar.gz"}})
+	assert.ErrorContains(t, err, "which belongs to another resource")
+	assert.FileExists(t, filepath.Join(dir, "tools", "one.txt"))
+	assert.FileExists(t, filepath.Join(dir, "tools", "two.txt"))
+}
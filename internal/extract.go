@@ -0,0 +1,321 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/rs/zerolog/log"
+	"github.com/ulikunitz/xz"
+)
+
+// Supported archive formats.
+const (
+	FormatTar    = "tar"
+	FormatTarGz  = "tar.gz"
+	FormatTarXz  = "tar.xz"
+	FormatTarZst = "tar.zst"
+	FormatZip    = "zip"
+)
+
+// Magic numbers of the supported archive and compression formats.
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	xzMagic   = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	zipMagic  = []byte{'P', 'K', 0x03, 0x04}
+	tarMagic  = []byte("ustar")
+)
+
+// tarMagicOffset is the offset of the magic string in a tar header.
+const tarMagicOffset = 257
+
+// archiveFormat detects the format of the archive file from its content.
+func archiveFormat(archive string) (string, error) {
+	file, err := os.Open(archive)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	header := make([]byte, tarMagicOffset+len(tarMagic))
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	header = header[:n]
+	switch {
+	case bytes.HasPrefix(header, gzipMagic):
+		return FormatTarGz, nil
+	case bytes.HasPrefix(header, xzMagic):
+		return FormatTarXz, nil
+	case bytes.HasPrefix(header, zstdMagic):
+		return FormatTarZst, nil
+	case bytes.HasPrefix(header, zipMagic):
+		return FormatZip, nil
+	case len(header) > tarMagicOffset && bytes.HasPrefix(header[tarMagicOffset:], tarMagic):
+		return FormatTar, nil
+	}
+	return "", fmt.Errorf("'%s' is not a supported archive (%s, %s, %s, %s or %s)", archive, FormatTar, FormatTarGz, FormatTarXz, FormatTarZst, FormatZip)
+}
+
+// extractArchive extracts the archive file into dir, removing the
+// first stripComponents elements of each entry name. Entries that would
+// be written outside of dir are rejected.
+func extractArchive(archive string, dir string, stripComponents int) error {
+	format, err := archiveFormat(archive)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	// All the files are created through root, which prevents escaping dir
+	// through symbolic links.
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+	if format == FormatZip {
+		err = extractZip(archive, root, stripComponents)
+	} else {
+		err = extractTar(archive, format, root, stripComponents)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to extract '%s': %w", archive, err)
+	}
+	return nil
+}
+
+// entryName returns the name entries called name are extracted as, or ""
+// if the entry is stripped entirely.
+func entryName(name string, stripComponents int) (string, error) {
+	name = strings.TrimSuffix(name, "/")
+	if name == "" || path.IsAbs(name) || strings.Contains(name, `\`) {
+		return "", fmt.Errorf("invalid entry name '%s'", name)
+	}
+	elems := strings.Split(path.Clean(name), "/")
+	if slices.Contains(elems, "..") {
+		return "", fmt.Errorf("entry '%s' is outside of the extraction directory", name)
+	}
+	if len(elems) <= stripComponents {
+		return "", nil
+	}
+	name = path.Join(elems[stripComponents:]...)
+	if name == "." {
+		return "", nil
+	}
+	if !filepath.IsLocal(filepath.FromSlash(name)) {
+		return "", fmt.Errorf("entry '%s' is outside of the extraction directory", name)
+	}
+	return name, nil
+}
+
+// extractTar extracts the tar archive file, compressed with the given
+// format, into root.
+func extractTar(archive string, format string, root *os.Root, stripComponents int) error {
+	file, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var r io.Reader = bufio.NewReader(file)
+	switch format {
+	case FormatTarGz:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	case FormatTarXz:
+		r, err = xz.NewReader(r)
+		if err != nil {
+			return err
+		}
+	case FormatTarZst:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+		name, err := entryName(header.Name, stripComponents)
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			continue
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = root.MkdirAll(name, 0755)
+		case tar.TypeReg:
+			err = writeEntry(root, name, tr, header.FileInfo().Mode())
+		case tar.TypeSymlink:
+			err = symlinkEntry(root, name, header.Linkname)
+		case tar.TypeLink:
+			var target string
+			target, err = entryName(header.Linkname, stripComponents)
+			if err == nil && target == "" {
+				err = fmt.Errorf("link target '%s' is stripped", header.Linkname)
+			}
+			if err == nil {
+				err = linkEntry(root, name, target)
+			}
+		default:
+			log.Debug().Msgf("Skipping '%s' of unsupported type '%c'", header.Name, header.Typeflag)
+		}
+		if err != nil {
+			return fmt.Errorf("cannot extract '%s': %w", header.Name, err)
+		}
+	}
+}
+
+// extractZip extracts the zip archive file into root.
+func extractZip(archive string, root *os.Root, stripComponents int) error {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		name, err := entryName(f.Name, stripComponents)
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			continue
+		}
+		err = extractZipEntry(f, root, name)
+		if err != nil {
+			return fmt.Errorf("cannot extract '%s': %w", f.Name, err)
+		}
+	}
+	return nil
+}
+
+// maxSymlinkSize bounds the size of the targets of symbolic links stored
+// in zip archives.
+const maxSymlinkSize = 4096
+
+func extractZipEntry(f *zip.File, root *os.Root, name string) error {
+	mode := f.Mode()
+	if mode.IsDir() {
+		return root.MkdirAll(name, 0755)
+	}
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if mode&os.ModeSymlink != 0 {
+		target, err := io.ReadAll(io.LimitReader(r, maxSymlinkSize))
+		if err != nil {
+			return err
+		}
+		return symlinkEntry(root, name, string(target))
+	}
+	if !mode.IsRegular() {
+		log.Debug().Msgf("Skipping '%s' of unsupported mode '%s'", f.Name, mode)
+		return nil
+	}
+	return writeEntry(root, name, r, mode)
+}
+
+// writeEntry writes the content of a regular file to name in root.
+func writeEntry(root *os.Root, name string, r io.Reader, mode os.FileMode) error {
+	err := prepareEntry(root, name)
+	if err != nil {
+		return err
+	}
+	perm := mode.Perm()
+	if perm == 0 {
+		perm = 0644
+	}
+	file, err := root.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, r)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return root.Chmod(name, perm)
+}
+
+// symlinkEntry creates a symbolic link called name pointing to target in
+// root. Links pointing outside of root are rejected.
+func symlinkEntry(root *os.Root, name string, target string) error {
+	if path.IsAbs(target) || !filepath.IsLocal(filepath.FromSlash(path.Join(path.Dir(name), target))) {
+		return fmt.Errorf("symbolic link to '%s' is outside of the extraction directory", target)
+	}
+	err := prepareEntry(root, name)
+	if err != nil {
+		return err
+	}
+	return root.Symlink(target, name)
+}
+
+// linkEntry creates a hard link called name to target in root.
+func linkEntry(root *os.Root, name string, target string) error {
+	err := prepareEntry(root, name)
+	if err != nil {
+		return err
+	}
+	return root.Link(target, name)
+}
+
+// prepareEntry creates the parent directory of name and removes any
+// previous file called name, so that extracting an archive again replaces
+// its previous content.
+func prepareEntry(root *os.Root, name string) error {
+	parent := path.Dir(name)
+	if parent != "." {
+		err := root.MkdirAll(parent, 0755)
+		if err != nil {
+			return err
+		}
+	}
+	info, err := root.Lstat(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if !info.IsDir() {
+		return root.Remove(name)
+	}
+	return nil
+}
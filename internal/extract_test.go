@@ -0,0 +1,180 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cisco-open/grabit/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testArchiveEntries = []test.ArchiveEntry{
+	{Name: "pkg-1.0/"},
+	{Name: "pkg-1.0/README", Content: "readme"},
+	{Name: "pkg-1.0/bin/tool", Content: "#!/bin/sh", Mode: 0755},
+	{Name: "pkg-1.0/bin/link", Link: "tool"},
+}
+
+func TestExtractArchive(t *testing.T) {
+	for _, format := range []string{FormatTar, FormatTarGz, FormatTarXz, FormatTarZst, FormatZip} {
+		t.Run(format, func(t *testing.T) {
+			archive := test.ArchiveFile(t, format, testArchiveEntries)
+			detected, err := archiveFormat(archive)
+			require.Nil(t, err)
+			assert.Equal(t, format, detected)
+
+			dir := test.TmpDir(t)
+			err = extractArchive(archive, dir, 0)
+			require.Nil(t, err)
+			test.AssertFileContains(t, filepath.Join(dir, "pkg-1.0", "README"), "readme")
+			info, err := os.Stat(filepath.Join(dir, "pkg-1.0", "bin", "tool"))
+			require.Nil(t, err)
+			assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+			target, err := os.Readlink(filepath.Join(dir, "pkg-1.0", "bin", "link"))
+			require.Nil(t, err)
+			assert.Equal(t, "tool", target)
+
+			// Extracting again overwrites the previous content.
+			err = extractArchive(archive, dir, 0)
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func TestExtractArchiveStripComponents(t *testing.T) {
+	archive := test.ArchiveFile(t, FormatTarGz, testArchiveEntries)
+	dir := test.TmpDir(t)
+	err := extractArchive(archive, dir, 1)
+	require.Nil(t, err)
+	test.AssertFileContains(t, filepath.Join(dir, "README"), "readme")
+	assert.FileExists(t, filepath.Join(dir, "bin", "tool"))
+	assert.NoDirExists(t, filepath.Join(dir, "pkg-1.0"))
+
+	dir = test.TmpDir(t)
+	err = extractArchive(archive, dir, 2)
+	require.Nil(t, err)
+	test.AssertFileContains(t, filepath.Join(dir, "tool"), "#!/bin/sh")
+	assert.NoFileExists(t, filepath.Join(dir, "README"))
+}
+
+func TestExtractArchiveUnsafe(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry test.ArchiveEntry
+	}{
+		{"parent", test.ArchiveEntry{Name: "../evil", Content: "evil"}},
+		{"nested parent", test.ArchiveEntry{Name: "a/../../evil", Content: "evil"}},
+		{"absolute", test.ArchiveEntry{Name: "/tmp/evil", Content: "evil"}},
+		{"absolute symlink", test.ArchiveEntry{Name: "link", Link: "/etc"}},
+		{"parent symlink", test.ArchiveEntry{Name: "a/link", Link: "../../etc"}},
+	}
+	for _, format := range []string{FormatTar, FormatZip} {
+		for _, data := range tests {
+			t.Run(fmt.Sprintf("%s %s", format, data.name), func(t *testing.T) {
+				archive := test.ArchiveFile(t, format, []test.ArchiveEntry{data.entry})
+				parent := test.TmpDir(t)
+				dir := filepath.Join(parent, "dir")
+				err := extractArchive(archive, dir, 0)
+				assert.NotNil(t, err)
+				assert.NoFileExists(t, filepath.Join(parent, "evil"))
+			})
+		}
+	}
+}
+
+func TestExtractArchiveThroughSymlink(t *testing.T) {
+	// A symbolic link to a directory within the extraction directory can be
+	// written through, but never outside of it.
+	archive := test.ArchiveFile(t, FormatTar, []test.ArchiveEntry{
+		{Name: "data/"},
+		{Name: "link", Link: "data"},
+		{Name: "link/file", Content: "content"},
+	})
+	dir := test.TmpDir(t)
+	err := extractArchive(archive, dir, 0)
+	require.Nil(t, err)
+	test.AssertFileContains(t, filepath.Join(dir, "data", "file"), "content")
+}
+
+func TestExtractArchiveNotArchive(t *testing.T) {
+	err := extractArchive(test.TmpFile(t, "not an archive"), test.TmpDir(t), 0)
+	assert.ErrorContains(t, err, "is not a supported archive")
+}
+
+func TestResourceDownloadExtract(t *testing.T) {
+	content := test.Archive(t, FormatTarGz, testArchiveEntries)
+	port := test.TestHttpHandler(content, t)
+	resource := Resource{
+		Urls:            []string{fmt.Sprintf("http://localhost:%d/pkg.tar.gz", port)},
+		Integrity:       test.GetSha256Integrity(content),
+		Extract:         true,
+		StripComponents: 1,
+		ExtractDir:      "pkg",
+	}
+	dir := test.TmpDir(t)
+	err := resource.Download(dir, 0644, context.Background(), DownloadOptions{})
+	require.Nil(t, err)
+	// The archive is kept so that its integrity can be checked.
+	test.AssertFileContains(t, filepath.Join(dir, "pkg.tar.gz"), content)
+	test.AssertFileContains(t, filepath.Join(dir, "pkg", "README"), "readme")
+
+	// The archive is extracted again when already present.
+	err = os.RemoveAll(filepath.Join(dir, "pkg"))
+	require.Nil(t, err)
+	err = resource.Download(dir, 0644, context.Background(), DownloadOptions{})
+	require.Nil(t, err)
+	test.AssertFileContains(t, filepath.Join(dir, "pkg", "README"), "readme")
+}
+
+func TestResourceDownloadExtractIntegrityMismatch(t *testing.T) {
+	content := test.Archive(t, FormatTarGz, testArchiveEntries)
+	port := test.TestHttpHandler(content, t)
+	resource := Resource{
+		Urls:      []string{fmt.Sprintf("http://localhost:%d/pkg.tar.gz", port)},
+		Integrity: test.GetSha256Integrity("something else"),
+		Extract:   true,
+	}
+	dir := test.TmpDir(t)
+	err := resource.Download(dir, 0644, context.Background(), DownloadOptions{})
+	assert.ErrorContains(t, err, "integrity mismatch")
+	assert.NoDirExists(t, filepath.Join(dir, "pkg-1.0"))
+}
+
+func TestResourceDownloadExtractDirOutside(t *testing.T) {
+	content := test.Archive(t, FormatTarGz, testArchiveEntries)
+	port := test.TestHttpHandler(content, t)
+	resource := Resource{
+		Urls:       []string{fmt.Sprintf("http://localhost:%d/pkg.tar.gz", port)},
+		Integrity:  test.GetSha256Integrity(content),
+		Extract:    true,
+		ExtractDir: "../pkg",
+	}
+	err := resource.Download(test.TmpDir(t), 0644, context.Background(), DownloadOptions{})
+	assert.ErrorContains(t, err, "is outside of")
+}
+
+func TestExtractDirIsSubdirectory(t *testing.T) {
+	for _, extractDir := range []string{".", "./", "pkg/..", "../pkg", "/pkg"} {
+		t.Run(extractDir, func(t *testing.T) {
+			_, err := NewResource(Resource{
+				Urls:       []string{"http://localhost:123456/pkg.tar.gz"},
+				Extract:    true,
+				ExtractDir: extractDir,
+			}, AddOptions{Algo: "sha256"})
+			assert.ErrorContains(t, err, "must be a relative path below the download directory")
+
+			// Lock files edited by hand are rejected at download time.
+			resource := Resource{Urls: []string{"http://localhost:123456/pkg.tar.gz"}, Extract: true, ExtractDir: extractDir}
+			_, err = resource.extractDir(test.TmpDir(t))
+			assert.NotNil(t, err)
+		})
+	}
+	assert.True(t, isSubdirectory("pkg/./1.0"))
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import "slices"
+
+// ResourceFilter selects resources of a lock file. The zero value selects
+// all of them.
+type ResourceFilter struct {
+	// Tags lists the tags the resources must all have.
+	Tags []string
+	// NoTags lists the tags the resources must have none of.
+	NoTags []string
+	// Urls, when not empty, lists the urls the resources must have one of.
+	Urls []string
+	// Integrities, when not empty, lists the integrities the resources
+	// must have one of.
+	Integrities []string
+}
+
+// NewTagFilter returns a filter selecting the resources that have all the
+// given tags and none of the given notags.
+func NewTagFilter(tags []string, notags []string) ResourceFilter {
+	return ResourceFilter{Tags: tags, NoTags: notags}
+}
+
+// Matches returns true if the resource is selected by the filter.
+func (f ResourceFilter) Matches(r *Resource) bool {
+	for _, tag := range f.Tags {
+		if !slices.Contains(r.Tags, tag) {
+			return false
+		}
+	}
+	for _, notag := range f.NoTags {
+		if slices.Contains(r.Tags, notag) {
+			return false
+		}
+	}
+	if len(f.Urls) > 0 && !slices.ContainsFunc(f.Urls, r.Contains) {
+		return false
+	}
+	if len(f.Integrities) > 0 && !slices.Contains(f.Integrities, r.Integrity) {
+		return false
+	}
+	return true
+}
+
+// Filter returns the resources of this lock file selected by f.
+func (l *Lock) Filter(f ResourceFilter) []Resource {
+	filteredResources := []Resource{}
+	for _, r := range l.conf.Resource {
+		if f.Matches(&r) {
+			filteredResources = append(filteredResources, r)
+		}
+	}
+	return filteredResources
+}
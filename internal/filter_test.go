@@ -0,0 +1,54 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"path"
+	"testing"
+
+	"github.com/cisco-open/grabit/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockFilter(t *testing.T) {
+	lockPath := test.TmpFile(t, `
+	[[Resource]]
+	Urls = ['http://localhost:123456/a']
+	Integrity = 'sha256-a'
+	Tags = ['tag1', 'tag2']
+
+	[[Resource]]
+	Urls = ['http://localhost:123456/b']
+	Integrity = 'sha256-b'
+	Tags = ['tag1']
+
+	[[Resource]]
+	Urls = ['http://localhost:123456/c']
+	Integrity = 'sha256-c'
+`)
+	lock, err := NewLock(lockPath, false)
+	require.Nil(t, err)
+	tests := []struct {
+		name   string
+		filter ResourceFilter
+		want   []string
+	}{
+		{"all", ResourceFilter{}, []string{"a", "b", "c"}},
+		{"tag", NewTagFilter([]string{"tag1"}, nil), []string{"a", "b"}},
+		{"tags", NewTagFilter([]string{"tag1", "tag2"}, nil), []string{"a"}},
+		{"notag", NewTagFilter(nil, []string{"tag2"}), []string{"b", "c"}},
+		{"tag and notag", NewTagFilter([]string{"tag1"}, []string{"tag2"}), []string{"b"}},
+		{"unknown tag", NewTagFilter([]string{"tag3"}, nil), []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, r := range lock.Filter(tt.filter) {
+				got = append(got, path.Base(r.Urls[0]))
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
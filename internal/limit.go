@@ -0,0 +1,52 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"context"
+	"net/url"
+	"sync"
+)
+
+// hostLimiter bounds the number of simultaneous connections to each host.
+// A nil hostLimiter does not limit anything.
+type hostLimiter struct {
+	limit int
+	mtx   sync.Mutex
+	slots map[string]chan struct{}
+}
+
+// newHostLimiter returns a limiter allowing limit simultaneous connections
+// per host, or nil if limit is not positive.
+func newHostLimiter(limit int) *hostLimiter {
+	if limit <= 0 {
+		return nil
+	}
+	return &hostLimiter{limit: limit, slots: map[string]chan struct{}{}}
+}
+
+// acquire waits for a free connection slot for the host of u. The returned
+// function must be called to release the slot.
+func (h *hostLimiter) acquire(ctx context.Context, u string) (func(), error) {
+	if h == nil {
+		return func() {}, nil
+	}
+	host := u
+	if parsed, err := url.Parse(u); err == nil {
+		host = parsed.Host
+	}
+	h.mtx.Lock()
+	slots, ok := h.slots[host]
+	if !ok {
+		slots = make(chan struct{}, h.limit)
+		h.slots[host] = slots
+	}
+	h.mtx.Unlock()
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
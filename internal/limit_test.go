@@ -0,0 +1,36 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostLimiter(t *testing.T) {
+	assert.Nil(t, newHostLimiter(0))
+	var nilLimiter *hostLimiter
+	release, err := nilLimiter.acquire(context.Background(), "http://localhost:33/test.html")
+	assert.Nil(t, err)
+	release()
+
+	limiter := newHostLimiter(1)
+	release, err = limiter.acquire(context.Background(), "http://localhost:33/test.html")
+	assert.Nil(t, err)
+	// Other hosts are not limited.
+	releaseOther, err := limiter.acquire(context.Background(), "http://localhost:34/test.html")
+	assert.Nil(t, err)
+	releaseOther()
+	// The host has no free slot left.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = limiter.acquire(ctx, "http://localhost:33/test2.html")
+	assert.ErrorIs(t, err, context.Canceled)
+	release()
+	release, err = limiter.acquire(context.Background(), "http://localhost:33/test2.html")
+	assert.Nil(t, err)
+	release()
+}
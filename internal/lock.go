@@ -4,81 +4,234 @@
 package internal
 
 import (
-	"bufio"
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
 	"strconv"
+	"strings"
 
+	"github.com/gofrs/flock"
 	toml "github.com/pelletier/go-toml/v2"
+	"github.com/rs/zerolog/log"
 )
 
-var COMMENT_PREFIX = "//"
+// COMMENT_PREFIX starts the comments of the lock file.
+var COMMENT_PREFIX = "#"
 
 // Lock represents a grabit lockfile.
 type Lock struct {
 	path string
 	conf config
+	// text is the text of the lock file, used to save it again without
+	// losing its comments and formatting. It is nil for new lock files and
+	// for the ones whose layout is not understood, which are saved from
+	// scratch.
+	text *lockText
+	// digest is the digest of the lock file when it was read, used to
+	// detect its modification by another process. It is nil if the lock
+	// file did not exist.
+	digest []byte
+	// fileLock is the advisory lock held from NewLockForUpdate to Unlock.
+	fileLock *flock.Flock
 }
 
 type config struct {
+	// Version is the version of the schema of the lock file.
+	Version  int `toml:",omitempty"`
 	Resource []Resource
 }
 
 func NewLock(path string, newOk bool) (*Lock, error) {
-	_, error := os.Stat(path)
-	if os.IsNotExist(error) {
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
 		if newOk {
-			return &Lock{path: path}, nil
+			return &Lock{path: path, conf: config{Version: LOCK_VERSION}}, nil
 		} else {
 			return nil, fmt.Errorf("file '%s' does not exist", path)
 		}
 	}
+	if err != nil {
+		return nil, err
+	}
 	var conf config
-	file, err := os.Open(path)
+	d := toml.NewDecoder(bytes.NewReader(content))
+	d.DisallowUnknownFields()
+	err = warnUnknownKeys(path, d.Decode(&conf))
 	if err != nil {
-		return nil, error
+		return nil, err
 	}
-	d := toml.NewDecoder(file)
-	err = d.Decode(&conf)
+	err = checkVersion(path, conf.Version)
 	if err != nil {
 		return nil, err
 	}
-
-	return &Lock{path: path, conf: conf}, nil
+	text := parseLockText(string(content), conf)
+	if text == nil {
+		log.Debug().Msgf("Lock file '%s' will be saved without its comments", path)
+	}
+	return &Lock{path: path, conf: conf, text: text, digest: contentDigest(content)}, nil
 }
 
 func (l *Lock) AddResource(paths []string, algo string, tags []string, filename string, cacheURL string) error {
-	for _, u := range paths {
+	_, err := l.Add(Resource{Urls: paths, Tags: tags, Filename: filename, ArtifactoryCacheURL: cacheURL}, AddOptions{Algo: algo})
+	return err
+}
+
+// Add adds a resource with the settings of template and the integrity of
+// its first url, and returns it. The relative Keyring and
+// SigstoreTrustedRoot paths of template, relative to the current
+// directory, are stored relative to the directory of the lock file.
+func (l *Lock) Add(template Resource, opts AddOptions) (*Resource, error) {
+	for _, u := range template.Urls {
 		if l.Contains(u) {
-			return fmt.Errorf("resource '%s' is already present", u)
+			return nil, &ResourceError{Url: u, Err: fmt.Errorf("resource '%s' is already present", u)}
 		}
 	}
-	r, err := NewResourceFromUrl(paths, algo, tags, filename, cacheURL)
+	var err error
+	template.Keyring, err = l.relativePath(template.Keyring)
+	if err != nil {
+		return nil, err
+	}
+	template.SigstoreTrustedRoot, err = l.relativePath(template.SigstoreTrustedRoot)
+	if err != nil {
+		return nil, err
+	}
+	opts.BaseDir = filepath.Dir(l.path)
+	r, err := NewResource(template, opts)
 
 	if err != nil {
-		return err
+		if len(template.Urls) == 0 {
+			return nil, err
+		}
+		return nil, &ResourceError{Url: template.Urls[0], Err: err}
 	}
 
 	l.conf.Resource = append(l.conf.Resource, *r)
-	return nil
+	return r, nil
+}
+
+// relativePath returns the path p, relative to the current directory, as
+// relative to the directory of the lock file.
+func (l *Lock) relativePath(p string) (string, error) {
+	if p == "" || filepath.IsAbs(p) {
+		return p, nil
+	}
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return "", err
+	}
+	lockDir, err := filepath.Abs(filepath.Dir(l.path))
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(lockDir, abs)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// Resources returns the resources of this lock file.
+func (l *Lock) Resources() []Resource {
+	return l.conf.Resource
 }
 
 func (l *Lock) DeleteResource(path string) error {
+	_, err := l.Delete(ResourceFilter{Urls: []string{path}})
+	return err
+}
+
+// Delete removes the resources selected by f from this lock file, along
+// with their Artifactory cache copy, and returns them.
+func (l *Lock) Delete(f ResourceFilter) ([]Resource, error) {
 	newStatements := []Resource{}
+	deleted := []Resource{}
 	for _, r := range l.conf.Resource {
-		if !r.Contains(path) {
+		if !f.Matches(&r) {
 			newStatements = append(newStatements, r)
 		} else {
 			err := r.Delete()
 			if err != nil {
-				return fmt.Errorf("Failed to delete resource '%s': %w", path, err)
+				return nil, &ResourceError{Url: r.Urls[0], Err: fmt.Errorf("Failed to delete resource '%s': %w", r.Urls[0], err)}
 			}
+			deleted = append(deleted, r)
 		}
 	}
 	l.conf.Resource = newStatements
-	return nil
+	return deleted, nil
+}
+
+// UpdateResult describes the integrity change of an updated resource.
+type UpdateResult struct {
+	Resource     Resource
+	OldIntegrity string
+	NewIntegrity string
+}
+
+// Changed returns true if the resource integrity changed.
+func (u *UpdateResult) Changed() bool {
+	return u.OldIntegrity != u.NewIntegrity
+}
+
+// Update downloads again the resources matching the given urls and tags
+// and updates their integrity. All resources are considered when no url
+// is given. When dryRun is true, the lock is left untouched and only the
+// results are returned.
+func (l *Lock) Update(urls []string, tags []string, notags []string, dryRun bool) ([]UpdateResult, error) {
+	for _, u := range urls {
+		if !l.Contains(u) {
+			return nil, fmt.Errorf("resource '%s' is not present", u)
+		}
+	}
+	results := []UpdateResult{}
+	manifests := []map[string]string{}
+	indexes := []int{}
+	errs := []error{}
+	filter := NewTagFilter(tags, notags)
+	for i := range l.conf.Resource {
+		r := &l.conf.Resource[i]
+		if !filter.Matches(r) {
+			continue
+		}
+		if len(urls) > 0 && !slices.ContainsFunc(urls, r.Contains) {
+			continue
+		}
+		algo, err := getAlgoFromIntegrity(r.Integrity)
+		if err != nil {
+			errs = append(errs, &ResourceError{Url: r.Urls[0], Err: fmt.Errorf("cannot update resource '%s': %w", r.Urls[0], err)})
+			continue
+		}
+		template := *r
+		if dryRun {
+			template.ArtifactoryCacheURL = ""
+		}
+		updated, err := NewResource(template, AddOptions{Algo: algo, Manifest: r.Manifest != nil, BaseDir: filepath.Dir(l.path)})
+		if err != nil {
+			errs = append(errs, &ResourceError{Url: r.Urls[0], Err: fmt.Errorf("cannot update resource '%s': %w", r.Urls[0], err)})
+			continue
+		}
+		results = append(results, UpdateResult{Resource: *r, OldIntegrity: r.Integrity, NewIntegrity: updated.Integrity})
+		manifests = append(manifests, updated.Manifest)
+		indexes = append(indexes, i)
+	}
+	if len(errs) > 0 {
+		return results, errors.Join(errs...)
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("nothing to update")
+	}
+	// Only modify the lock once all the resources were fetched successfully.
+	if !dryRun {
+		for j, i := range indexes {
+			l.conf.Resource[i].Integrity = results[j].NewIntegrity
+			l.conf.Resource[i].Manifest = manifests[j]
+		}
+	}
+	return results, nil
 }
 
 const NoFileMode = os.FileMode(0)
@@ -96,128 +249,140 @@ func strToFileMode(perm string) (os.FileMode, error) {
 }
 
 // Download gets all the resources in this lock file and moves them to
-// the destination directory.
-func (l *Lock) Download(dir string, tags []string, notags []string, perm string, status bool) error {
+// the destination directory. It returns the result of every resource
+// matching the given tags, along with the errors of all the failed ones.
+// The downloads are interrupted when ctx is done.
+func (l *Lock) Download(dir string, tags []string, notags []string, perm string, status bool, opts DownloadOptions, parentCtx context.Context) ([]DownloadResult, error) {
 	if stat, err := os.Stat(dir); err != nil || !stat.IsDir() {
-		return fmt.Errorf("'%s' is not a directory", dir)
+		return nil, fmt.Errorf("'%s' is not a directory", dir)
 	}
 	mode, err := strToFileMode(perm)
 	if err != nil {
-		return fmt.Errorf("'%s' is not a valid permission definition", perm)
+		return nil, fmt.Errorf("'%s' is not a valid permission definition", perm)
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(parentCtx)
 	defer cancel()
-	// Filter in the resources that have all the required tags.
-	tagFilteredResources := []Resource{}
-	if len(tags) > 0 {
-		for _, r := range l.conf.Resource {
-			hasAllTags := true
-			for _, tag := range tags {
-				hasTag := false
-				for _, rtag := range r.Tags {
-					if tag == rtag {
-						hasTag = true
-						break
-					}
-				}
-				if !hasTag {
-					hasAllTags = false
-					break
-				}
-			}
-			if hasAllTags {
-				tagFilteredResources = append(tagFilteredResources, r)
-			}
-		}
-	} else {
-		tagFilteredResources = l.conf.Resource
-	}
-	// Filter out the resources that have any 'notag' tag.
-	filteredResources := []Resource{}
-	if len(notags) > 0 {
-		for _, r := range tagFilteredResources {
-			hasTag := false
-			for _, notag := range notags {
-				for _, rtag := range r.Tags {
-					if notag == rtag {
-						hasTag = true
-					}
-				}
-			}
-			if !hasTag {
-				filteredResources = append(filteredResources, r)
-			}
-		}
-	} else {
-		filteredResources = tagFilteredResources
-	}
+	filteredResources := l.Filter(NewTagFilter(tags, notags))
 
 	total := len(filteredResources)
 	if total == 0 {
-		return fmt.Errorf("nothing to download")
+		return nil, fmt.Errorf("nothing to download")
 	}
-	errorCh := make(chan error, total)
+	// Each worker stores the result of resource i at results[i] before
+	// sending i on doneCh.
+	results := make([]DownloadResult, total)
+	doneCh := make(chan int, total)
 
 	var statusLine *StatusLine
 	if status {
 		statusLine = NewStatusLine(ctx, &filteredResources)
-		err := statusLine.InitResourcesSizes()
-		if err == nil {
+		if opts.Offline {
+			// Sizes are fetched with HEAD requests: go without them.
 			statusLine.Start(true)
 		} else {
-			statusLine = nil // Do not update or display SL.
+			err := statusLine.InitResourcesSizes()
+			if err == nil {
+				statusLine.Start(true)
+			} else {
+				statusLine = nil // Do not update or display SL.
+			}
 		}
 	}
+	if statusLine != nil {
+		// Terminate the status line before returning.
+		defer statusLine.Wait()
+		defer cancel()
+	}
 
-	for i, r := range filteredResources {
-		resource := r
+	jobs := opts.Jobs
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+	opts.hosts = newHostLimiter(opts.JobsPerHost)
+	if opts.BaseDir == "" {
+		opts.BaseDir = filepath.Dir(l.path)
+	}
+	// Feed a bounded pool of workers with the resource indexes.
+	indexCh := make(chan int)
+	go func() {
+		for i := range filteredResources {
+			indexCh <- i
+		}
+		close(indexCh)
+	}()
+	for range min(jobs, total) {
 		go func() {
-
-			err := resource.Download(dir, mode, ctx)
-			errorCh <- err
-
-			if statusLine != nil {
-				statusLine.Increment(i)
+			for i := range indexCh {
+				if ctx.Err() != nil {
+					results[i] = DownloadResult{Resource: filteredResources[i], Err: errSkipped}
+				} else {
+					results[i] = filteredResources[i].downloadWithResult(dir, mode, ctx, opts)
+					if results[i].Err != nil && opts.FailFast && ctx.Err() == nil {
+						log.Debug().Msg("Cancelling the remaining downloads")
+						cancel()
+					}
+				}
+				// Cancelled resources are not reported as downloaded.
+				if statusLine != nil && ctx.Err() == nil {
+					statusLine.Increment(i)
+				}
+				doneCh <- i
 			}
 		}()
 	}
-	done := 0
-	errs := []error{}
 	for range total {
-		err = <-errorCh
-		if err != nil {
-			errs = append(errs, err)
-		} else {
-			done += 1
+		<-doneCh
+	}
+	if parentCtx.Err() != nil {
+		return results, fmt.Errorf("download interrupted: %w", parentCtx.Err())
+	}
+	downloadErrs := []error{}
+	offlineErrs := []error{}
+	skipped := []string{}
+	for i, res := range results {
+		err := res.Err
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrOffline):
+			offlineErrs = append(offlineErrs, err)
+		case opts.FailFast && (errors.Is(err, errSkipped) || errors.Is(err, context.Canceled)):
+			skipped = append(skipped, fmt.Sprintf("'%s'", filteredResources[i].Urls[0]))
+		default:
+			downloadErrs = append(downloadErrs, err)
 		}
 	}
-	if done == total {
-		return nil
+	if len(offlineErrs) > 0 {
+		// Report all the unsatisfiable resources together.
+		downloadErrs = append(downloadErrs, fmt.Errorf("%d resources cannot be satisfied offline:\n%w", len(offlineErrs), errors.Join(offlineErrs...)))
 	}
-	if len(errs) > 0 {
-		return errors.Join(errs...)
+	if len(skipped) > 0 {
+		downloadErrs = append(downloadErrs, fmt.Errorf("%d resources skipped because of an earlier failure: %s", len(skipped), strings.Join(skipped, ", ")))
 	}
-	return nil
+	return results, errors.Join(downloadErrs...)
 }
 
-// Save this lock file to disk.
+// errSkipped is the error of resources not downloaded because the
+// download was cancelled before they started.
+var errSkipped = errors.New("download skipped")
+
+// Save writes the lock file atomically. The comments and formatting of the
+// resources already present are kept, so that only the modified settings
+// change. It fails if the lock file was modified since it was read.
 func (l *Lock) Save() error {
-	res, err := toml.Marshal(l.conf)
-	if err != nil {
-		return err
+	text := l.text
+	if text == nil {
+		text = &lockText{}
 	}
-	file, err := os.Create(l.path)
+	res, err := text.render(l.conf)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-	w := bufio.NewWriter(file)
-	_, err = w.Write(res)
+	err = l.write(res)
 	if err != nil {
 		return err
 	}
-	w.Flush()
+	l.text = parseLockText(res, l.conf)
 	return nil
 }
 
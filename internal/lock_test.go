@@ -4,14 +4,18 @@
 package internal
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/cisco-open/grabit/test"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewLockInvalid(t *testing.T) {
@@ -61,6 +65,35 @@ func TestLockManipulations(t *testing.T) {
 	assert.Equal(t, 1, len(lock.conf.Resource))
 }
 
+func TestLockDelete(t *testing.T) {
+	path := test.TmpFile(t, `
+	[[Resource]]
+	Urls = ['http://localhost:123456/a.html']
+	Integrity = 'sha256-a'
+	Tags = ['tag']
+
+	[[Resource]]
+	Urls = ['http://localhost:123456/b.html']
+	Integrity = 'sha256-b'
+	Tags = ['tag', 'other']
+
+	[[Resource]]
+	Urls = ['http://localhost:123456/c.html']
+	Integrity = 'sha256-c'
+`)
+	lock, err := NewLock(path, false)
+	require.Nil(t, err)
+	deleted, err := lock.Delete(ResourceFilter{Tags: []string{"tag"}, NoTags: []string{"other"}})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(deleted))
+	assert.Equal(t, "sha256-a", deleted[0].Integrity)
+	deleted, err = lock.Delete(ResourceFilter{Integrities: []string{"sha256-c"}})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(deleted))
+	assert.Equal(t, []string{"http://localhost:123456/b.html"}, lock.conf.Resource[0].Urls)
+	assert.Equal(t, 1, len(lock.conf.Resource))
+}
+
 func TestDuplicateResource(t *testing.T) {
 	url := "http://localhost:123456/test.html"
 	path := test.TmpFile(t, fmt.Sprintf(`
@@ -116,7 +149,7 @@ func TestDownload(t *testing.T) {
 	lock, err := NewLock(path, false)
 	assert.Nil(t, err)
 	dir := test.TmpDir(t)
-	err = lock.Download(dir, []string{}, []string{}, perm, false)
+	_, err = lock.Download(dir, []string{}, []string{}, perm, false, DownloadOptions{}, context.Background())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -133,3 +166,306 @@ func TestDownload(t *testing.T) {
 	}
 	assert.Equal(t, stats.Mode().Perm().String(), strPerm)
 }
+
+func TestLockUpdate(t *testing.T) {
+	content := `abcdef`
+	contentIntegrity := test.GetSha256Integrity(content)
+	port := test.TestHttpHandler(content, t)
+	path := test.TmpFile(t, fmt.Sprintf(`
+		[[Resource]]
+		Urls = ['http://localhost:%d/test.html']
+		Integrity = 'sha256-outdated'
+		Tags = ['tag']
+		Filename = 'file.html'
+
+		[[Resource]]
+		Urls = ['http://localhost:%d/test2.html']
+		Integrity = 'sha256-outdated'`, port, port))
+	lock, err := NewLock(path, false)
+	assert.Nil(t, err)
+
+	results, err := lock.Update([]string{}, []string{"tag"}, []string{}, true)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(results))
+	assert.True(t, results[0].Changed())
+	assert.Equal(t, "sha256-outdated", results[0].OldIntegrity)
+	assert.Equal(t, contentIntegrity, results[0].NewIntegrity)
+	assert.Equal(t, "sha256-outdated", lock.conf.Resource[0].Integrity)
+
+	results, err = lock.Update([]string{fmt.Sprintf("http://localhost:%d/test.html", port)}, []string{}, []string{}, false)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(results))
+	assert.Equal(t, contentIntegrity, lock.conf.Resource[0].Integrity)
+	assert.Equal(t, []string{"tag"}, lock.conf.Resource[0].Tags)
+	assert.Equal(t, "file.html", lock.conf.Resource[0].Filename)
+	assert.Equal(t, "sha256-outdated", lock.conf.Resource[1].Integrity)
+
+	_, err = lock.Update([]string{"http://localhost:33/unknown.html"}, []string{}, []string{}, false)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "is not present")
+}
+
+func TestDownloadOffline(t *testing.T) {
+	content := `abcdef`
+	contentIntegrity := test.GetSha256Integrity(content)
+	requests := 0
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, err := w.Write([]byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	port, server := test.HttpHandler(handler)
+	defer server.Close()
+	path := test.TmpFile(t, fmt.Sprintf(`
+		[[Resource]]
+		Urls = ['http://localhost:%d/test.html']
+		Integrity = '%s'
+
+		[[Resource]]
+		Urls = ['http://localhost:%d/test2.html']
+		Integrity = '%s'
+
+		[[Resource]]
+		Urls = ['http://localhost:%d/test3.html']
+		Integrity = '%s'`, port, contentIntegrity, port, contentIntegrity, port, contentIntegrity))
+	lock, err := NewLock(path, false)
+	assert.Nil(t, err)
+	dir := test.TmpDir(t)
+	err = os.WriteFile(filepath.Join(dir, "test.html"), []byte(content), 0644)
+	assert.Nil(t, err)
+
+	_, err = lock.Download(dir, []string{}, []string{}, "", true, DownloadOptions{Offline: true}, context.Background())
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, requests)
+	assert.ErrorIs(t, err, ErrOffline)
+	assert.Contains(t, err.Error(), "2 resources cannot be satisfied offline")
+	assert.Contains(t, err.Error(), "test2.html")
+	assert.Contains(t, err.Error(), "test3.html")
+}
+
+func TestDownloadResults(t *testing.T) {
+	content := `abcdef`
+	contentIntegrity := test.GetSha256Integrity(content)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.html" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, err := w.Write([]byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	port, server := test.HttpHandler(handler)
+	defer server.Close()
+	path := test.TmpFile(t, fmt.Sprintf(`
+		[[Resource]]
+		Urls = ['http://localhost:%d/test.html']
+		Integrity = '%s'
+
+		[[Resource]]
+		Urls = ['http://localhost:%d/missing.html']
+		Integrity = '%s'`, port, contentIntegrity, port, test.GetSha256Integrity("missing")))
+	lock, err := NewLock(path, false)
+	require.Nil(t, err)
+	cache, err := NewCache(test.TmpDir(t))
+	require.Nil(t, err)
+	opts := DownloadOptions{Cache: cache, Retry: RetryPolicy{Retries: 0}}
+
+	dir := test.TmpDir(t)
+	results, err := lock.Download(dir, []string{}, []string{}, "", false, opts, context.Background())
+	assert.ErrorContains(t, err, "missing.html")
+	require.Len(t, results, 2)
+	assert.Nil(t, results[0].Err)
+	assert.Equal(t, filepath.Join(dir, "test.html"), results[0].Path)
+	assert.Equal(t, fmt.Sprintf("http://localhost:%d/test.html", port), results[0].Url)
+	assert.Equal(t, int64(len(content)), results[0].Bytes)
+	assert.False(t, results[0].CacheHit)
+	assert.Equal(t, contentIntegrity, results[0].Resource.Integrity)
+	assert.NotNil(t, results[1].Err)
+	assert.Empty(t, results[1].Path)
+
+	// The second download is satisfied from the local cache.
+	results, _ = lock.Download(test.TmpDir(t), []string{}, []string{}, "", false, opts, context.Background())
+	require.Len(t, results, 2)
+	assert.Nil(t, results[0].Err)
+	assert.True(t, results[0].CacheHit)
+	assert.Empty(t, results[0].Url)
+	assert.Equal(t, int64(len(content)), results[0].Bytes)
+}
+
+func TestDownloadConcurrency(t *testing.T) {
+	content := `abcdef`
+	contentIntegrity := test.GetSha256Integrity(content)
+	var active, maxActive atomic.Int32
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		n := active.Add(1)
+		defer active.Add(-1)
+		for {
+			m := maxActive.Load()
+			if n <= m || maxActive.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		_, err := w.Write([]byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	port, server := test.HttpHandler(handler)
+	defer server.Close()
+	lockContent := ""
+	for i := range 6 {
+		lockContent += fmt.Sprintf(`
+		[[Resource]]
+		Urls = ['http://localhost:%d/test%d.html']
+		Integrity = '%s'
+		`, port, i, contentIntegrity)
+	}
+	path := test.TmpFile(t, lockContent)
+	lock, err := NewLock(path, false)
+	require.Nil(t, err)
+
+	tests := []struct {
+		name        string
+		jobs        int
+		jobsPerHost int
+		max         int32
+	}{
+		{"jobs", 2, 0, 2},
+		{"jobs per host", 4, 1, 1},
+	}
+	for _, data := range tests {
+		t.Run(data.name, func(t *testing.T) {
+			maxActive.Store(0)
+			_, err = lock.Download(test.TmpDir(t), []string{}, []string{}, "", false, DownloadOptions{Jobs: data.jobs, JobsPerHost: data.jobsPerHost}, context.Background())
+			require.Nil(t, err)
+			assert.LessOrEqual(t, maxActive.Load(), data.max)
+			assert.Greater(t, maxActive.Load(), int32(0))
+		})
+	}
+}
+
+func TestDownloadFailFast(t *testing.T) {
+	content := `abcdef`
+	contentIntegrity := test.GetSha256Integrity(content)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow.html" {
+			// Send part of the content then hang until cancelled.
+			w.Header().Set("Content-Length", "1000")
+			_, _ = w.Write([]byte(content))
+			w.(http.Flusher).Flush()
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		_, err := w.Write([]byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	port, server := test.HttpHandler(handler)
+	defer server.Close()
+	path := test.TmpFile(t, fmt.Sprintf(`
+		[[Resource]]
+		Urls = ['http://localhost:%d/bad.html']
+		Integrity = 'sha256-bogus'
+
+		[[Resource]]
+		Urls = ['http://localhost:%d/slow.html']
+		Integrity = '%s'
+
+		[[Resource]]
+		Urls = ['http://localhost:%d/test.html']
+		Integrity = '%s'`, port, port, contentIntegrity, port, contentIntegrity))
+	lock, err := NewLock(path, false)
+	require.Nil(t, err)
+	dir := test.TmpDir(t)
+
+	start := time.Now()
+	_, err = lock.Download(dir, []string{}, []string{}, "", false, DownloadOptions{Jobs: 2, FailFast: true}, context.Background())
+	assert.Less(t, time.Since(start), 4*time.Second)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "integrity mismatch")
+	assert.Contains(t, err.Error(), "2 resources skipped because of an earlier failure")
+	assert.Contains(t, err.Error(), "slow.html")
+	assert.Contains(t, err.Error(), "test.html")
+	files, err := os.ReadDir(dir)
+	require.Nil(t, err)
+	assert.Empty(t, files)
+}
+
+func TestDownloadInterrupted(t *testing.T) {
+	content := `abcdef`
+	contentIntegrity := test.GetSha256Integrity(content)
+	started := make(chan struct{})
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		// Send part of the content then hang until cancelled.
+		w.Header().Set("Content-Length", "1000")
+		if r.Method == http.MethodHead {
+			return
+		}
+		_, _ = w.Write([]byte(content))
+		w.(http.Flusher).Flush()
+		started <- struct{}{}
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}
+	port, server := test.HttpHandler(handler)
+	defer server.Close()
+	path := test.TmpFile(t, fmt.Sprintf(`
+		[[Resource]]
+		Urls = ['http://localhost:%d/test.html']
+		Integrity = '%s'
+
+		[[Resource]]
+		Urls = ['http://localhost:%d/test2.html']
+		Integrity = '%s'`, port, contentIntegrity, port, contentIntegrity))
+	lock, err := NewLock(path, false)
+	require.Nil(t, err)
+	dir := test.TmpDir(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-started
+		<-started
+		cancel()
+	}()
+	start := time.Now()
+	_, err = lock.Download(dir, []string{}, []string{}, "", true, DownloadOptions{Jobs: 2}, ctx)
+	assert.Less(t, time.Since(start), 4*time.Second)
+	require.NotNil(t, err)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Contains(t, err.Error(), "download interrupted")
+	// The partially downloaded temporary files are removed.
+	files, err := os.ReadDir(dir)
+	require.Nil(t, err)
+	assert.Empty(t, files)
+}
+
+func TestLockUpdateManifest(t *testing.T) {
+	content := test.Archive(t, FormatTar, []test.ArchiveEntry{{Name: "README", Content: "new"}})
+	port := test.TestHttpHandler(content, t)
+	path := test.TmpFile(t, fmt.Sprintf(`
+		[[Resource]]
+		Urls = ['http://localhost:%d/pkg.tar']
+		Integrity = 'sha256-AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA='
+		Extract = true
+
+		[Resource.Manifest]
+		'README' = 'sha256-AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA='`, port))
+	lock, err := NewLock(path, false)
+	require.Nil(t, err)
+	_, err = lock.Update([]string{}, []string{}, []string{}, false)
+	require.Nil(t, err)
+	assert.Equal(t, test.GetSha256Integrity(content), lock.conf.Resource[0].Integrity)
+	assert.Equal(t, map[string]string{"README": test.GetSha256Integrity("new")}, lock.conf.Resource[0].Manifest)
+}
@@ -0,0 +1,167 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/gofrs/flock"
+	"github.com/rs/zerolog/log"
+)
+
+// fileLockPath returns the path of the file locked while modifying the
+// lock file at path. The lock file itself cannot be locked as it is
+// replaced. The file is kept in the temporary directory, out of the
+// repository of the lock file, under a name derived from its real path.
+func fileLockPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	} else if resolved, err := filepath.EvalSymlinks(filepath.Dir(path)); err == nil {
+		// New lock files.
+		path = filepath.Join(resolved, filepath.Base(path))
+	}
+	digest := sha256.Sum256([]byte(path))
+	return filepath.Join(os.TempDir(), fmt.Sprintf("grabit-%x.flock", digest[:8]))
+}
+
+// lockFile takes the advisory lock of the lock file at path, waiting for
+// the other commands modifying it to release it.
+func lockFile(path string) (*flock.Flock, error) {
+	lockPath := fileLockPath(path)
+	for {
+		// Other users can lock the file too.
+		fileLock := flock.New(lockPath, flock.SetFlag(os.O_CREATE|os.O_RDONLY), flock.SetPermissions(0644))
+		locked, err := fileLock.TryLock()
+		if err == nil && !locked {
+			log.Info().Msgf("Waiting for another command to finish modifying '%s'", path)
+			err = fileLock.Lock()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot lock '%s': %w", path, err)
+		}
+		// The file is removed by unlockFile once unlocked: the ones waiting
+		// for it then lock a new one.
+		lockedStat, err := fileLock.Stat()
+		if err == nil {
+			var currentStat os.FileInfo
+			currentStat, err = os.Stat(lockPath)
+			if err == nil && os.SameFile(lockedStat, currentStat) {
+				return fileLock, nil
+			}
+		}
+		fileLock.Unlock()
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("cannot lock '%s': %w", path, err)
+		}
+	}
+}
+
+// unlockFile removes the file locked by lockFile and releases its lock.
+func unlockFile(fileLock *flock.Flock) error {
+	// The file is removed before being unlocked so that nobody locks it
+	// afterwards. It cannot be removed while open on some systems, where it
+	// is simply left behind.
+	_ = os.Remove(fileLock.Path())
+	return fileLock.Unlock()
+}
+
+// NewLockForUpdate reads the lock file at path as NewLock does, and holds
+// its advisory lock until Unlock is called, so that the commands reading,
+// modifying and saving it are serialized.
+func NewLockForUpdate(path string, newOk bool) (*Lock, error) {
+	fileLock, err := lockFile(path)
+	if err != nil {
+		return nil, err
+	}
+	l, err := NewLock(path, newOk)
+	if err != nil {
+		unlockFile(fileLock)
+		return nil, err
+	}
+	l.fileLock = fileLock
+	return l, nil
+}
+
+// Unlock releases the advisory lock taken by NewLockForUpdate.
+func (l *Lock) Unlock() error {
+	if l.fileLock == nil {
+		return nil
+	}
+	err := unlockFile(l.fileLock)
+	l.fileLock = nil
+	return err
+}
+
+// contentDigest returns the digest of the content of a lock file, or nil
+// if it does not exist.
+func contentDigest(content []byte) []byte {
+	if content == nil {
+		return nil
+	}
+	digest := sha256.Sum256(content)
+	return digest[:]
+}
+
+// write replaces the lock file with content. Unless already held since
+// the lock file was read, it takes its advisory lock so that concurrent
+// writers are serialized, and refuses to overwrite the changes made by
+// another one since the lock file was read. The lock file is never
+// partially written: readers see either its old or its new content, so
+// they do not need to lock it.
+func (l *Lock) write(content string) error {
+	if l.fileLock == nil {
+		fileLock, err := lockFile(l.path)
+		if err != nil {
+			return err
+		}
+		defer unlockFile(fileLock)
+	}
+	current, err := os.ReadFile(l.path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if !bytes.Equal(contentDigest(current), l.digest) {
+		return fmt.Errorf("lock file '%s' was modified by another process since it was read: run the command again", l.path)
+	}
+	// Keep symbolic links to the lock file.
+	target := l.path
+	if resolved, err := filepath.EvalSymlinks(l.path); err == nil {
+		target = resolved
+	}
+	mode := os.FileMode(0644)
+	if stat, err := os.Stat(target); err == nil {
+		mode = stat.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.WriteString(content)
+	if err == nil {
+		err = tmp.Chmod(mode)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("cannot write lock file '%s': %w", l.path, err)
+	}
+	err = os.Rename(tmp.Name(), target)
+	if err != nil {
+		return fmt.Errorf("cannot write lock file '%s': %w", l.path, err)
+	}
+	l.digest = contentDigest([]byte(content))
+	return nil
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cisco-open/grabit/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const singleResourceLock = `[[Resource]]
+Urls = ['http://localhost:123456/a.html']
+Integrity = 'sha256-a'
+`
+
+func TestLockSaveConcurrentModification(t *testing.T) {
+	path := test.TmpFile(t, singleResourceLock)
+	first, err := NewLock(path, false)
+	require.Nil(t, err)
+	second, err := NewLock(path, false)
+	require.Nil(t, err)
+
+	first.conf.Resource[0].Integrity = "sha256-first"
+	err = first.Save()
+	assert.Nil(t, err)
+	second.conf.Resource[0].Integrity = "sha256-second"
+	err = second.Save()
+	assert.ErrorContains(t, err, "was modified by another process")
+	test.AssertFileContains(t, path, strings.Replace(singleResourceLock, "sha256-a", "sha256-first", 1))
+
+	// The lock file can be saved again by the process that wrote it.
+	first.conf.Resource[0].Integrity = "sha256-again"
+	err = first.Save()
+	assert.Nil(t, err)
+	test.AssertFileContains(t, path, strings.Replace(singleResourceLock, "sha256-a", "sha256-again", 1))
+}
+
+func TestLockSaveCreatedConcurrently(t *testing.T) {
+	path := filepath.Join(test.TmpDir(t), "grabit.lock")
+	lock, err := NewLock(path, true)
+	require.Nil(t, err)
+	require.Nil(t, os.WriteFile(path, []byte(singleResourceLock), 0644))
+	err = lock.Save()
+	assert.ErrorContains(t, err, "was modified by another process")
+	test.AssertFileContains(t, path, singleResourceLock)
+}
+
+func TestLockSaveAtomically(t *testing.T) {
+	dir := test.TmpDir(t)
+	target := filepath.Join(dir, "grabit.lock")
+	require.Nil(t, os.WriteFile(target, []byte(singleResourceLock), 0600))
+	link := filepath.Join(dir, "link.lock")
+	require.Nil(t, os.Symlink(target, link))
+	lock, err := NewLock(link, false)
+	require.Nil(t, err)
+	lock.conf.Resource[0].Integrity = "sha256-new"
+	err = lock.Save()
+	require.Nil(t, err)
+
+	stat, err := os.Lstat(link)
+	require.Nil(t, err)
+	assert.Equal(t, os.ModeSymlink, stat.Mode().Type())
+	stat, err = os.Stat(target)
+	require.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), stat.Mode().Perm())
+	test.AssertFileContains(t, target, strings.Replace(singleResourceLock, "sha256-a", "sha256-new", 1))
+	entries, err := os.ReadDir(dir)
+	require.Nil(t, err)
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	// No temporary file is left behind.
+	assert.ElementsMatch(t, []string{"grabit.lock", "link.lock"}, names)
+	assert.Equal(t, fileLockPath(target), fileLockPath(link))
+}
+
+func TestNewLockForUpdateSerializesModifications(t *testing.T) {
+	path := filepath.Join(test.TmpDir(t), "grabit.lock")
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lock, err := NewLockForUpdate(path, true)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer lock.Unlock()
+			lock.conf.Resource = append(lock.conf.Resource, Resource{Urls: []string{fmt.Sprintf("http://localhost:123456/%d.html", i)}, Integrity: "sha256-a"})
+			errs <- lock.Save()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.Nil(t, err)
+	}
+	lock, err := NewLock(path, false)
+	require.Nil(t, err)
+	assert.Equal(t, 10, len(lock.conf.Resource))
+	// The file locked is removed once unlocked.
+	assert.NoFileExists(t, fileLockPath(path))
+}
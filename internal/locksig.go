@@ -0,0 +1,78 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"aead.dev/minisign"
+	toml "github.com/pelletier/go-toml/v2"
+)
+
+// LOCK_KEY_PASSWORD_ENV_VAR holds the password of the minisign key used
+// to sign lock files.
+const LOCK_KEY_PASSWORD_ENV_VAR = "GRABIT_LOCK_KEY_PASSWORD"
+
+// LockSignaturePath returns the default path of the signature of the lock
+// file at path.
+func LockSignaturePath(path string) string {
+	return path + ".minisig"
+}
+
+// Canonical returns the canonical serialisation of the resources of this
+// lock file, which its signature covers. It does not depend on the
+// formatting of the file.
+func (l *Lock) Canonical() ([]byte, error) {
+	return toml.Marshal(l.conf)
+}
+
+// Sign writes a minisign signature of this lock file to signaturePath,
+// using the encrypted minisign private key file at keyPath.
+func (l *Lock) Sign(keyPath string, password string, signaturePath string) error {
+	privateKey, err := minisign.PrivateKeyFromFile(password, keyPath)
+	if err != nil {
+		return fmt.Errorf("cannot read signing key '%s': %w", keyPath, err)
+	}
+	content, err := l.Canonical()
+	if err != nil {
+		return err
+	}
+	trustedComment := fmt.Sprintf("timestamp:%d\tfile:%s", time.Now().Unix(), filepath.Base(l.path))
+	signature := minisign.SignWithComments(privateKey, content, trustedComment, "signature from grabit")
+	err = os.WriteFile(signaturePath, signature, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot write signature '%s': %w", signaturePath, err)
+	}
+	return nil
+}
+
+// VerifySignature checks the minisign signature of this lock file at
+// signaturePath against publicKey, the content of a minisign public key
+// file or the base64 key alone.
+func (l *Lock) VerifySignature(publicKey string, signaturePath string) error {
+	var key minisign.PublicKey
+	err := key.UnmarshalText([]byte(strings.TrimSpace(publicKey)))
+	if err != nil {
+		return fmt.Errorf("invalid public key: %w", err)
+	}
+	signature, err := os.ReadFile(signaturePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("lock file '%s' is not signed: '%s' does not exist", l.path, signaturePath)
+		}
+		return fmt.Errorf("cannot read signature '%s': %w", signaturePath, err)
+	}
+	content, err := l.Canonical()
+	if err != nil {
+		return err
+	}
+	if !minisign.Verify(key, content, signature) {
+		return fmt.Errorf("invalid signature '%s' of lock file '%s'", signaturePath, l.path)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"os"
+	"testing"
+
+	"github.com/cisco-open/grabit/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockSignature(t *testing.T) {
+	path := test.TmpFile(t, `
+	[[Resource]]
+	Urls = ['http://localhost:123456/test.html']
+	Integrity = 'sha256-asdasdasd'
+	Tags = ['tag1', 'tag2']
+`)
+	lock, err := NewLock(path, false)
+	require.Nil(t, err)
+	keyFile, publicKey := test.NewMinisignKeyFile(t, "secret")
+	_, otherPublicKey := test.NewMinisignKeyFile(t, "secret")
+	signature := LockSignaturePath(path)
+
+	err = lock.VerifySignature(publicKey, signature)
+	assert.ErrorContains(t, err, "is not signed")
+
+	err = lock.Sign(keyFile, "wrong", signature)
+	assert.ErrorContains(t, err, "cannot read signing key")
+	err = lock.Sign(keyFile, "secret", signature)
+	require.Nil(t, err)
+	assert.Nil(t, lock.VerifySignature(publicKey, signature))
+	assert.ErrorContains(t, lock.VerifySignature(otherPublicKey, signature), "invalid signature")
+	assert.ErrorContains(t, lock.VerifySignature("abcdef", signature), "invalid public key")
+
+	// The signature does not depend on the formatting of the lock file.
+	err = lock.Save()
+	require.Nil(t, err)
+	lock, err = NewLock(path, false)
+	require.Nil(t, err)
+	assert.Nil(t, lock.VerifySignature(publicKey, signature))
+
+	lock.conf.Resource[0].Integrity = "sha256-tampered"
+	assert.ErrorContains(t, lock.VerifySignature(publicKey, signature), "invalid signature")
+
+	err = os.WriteFile(signature, []byte("garbage"), 0644)
+	require.Nil(t, err)
+	assert.ErrorContains(t, lock.VerifySignature(publicKey, signature), "invalid signature")
+}
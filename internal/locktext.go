@@ -0,0 +1,303 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	toml "github.com/pelletier/go-toml/v2"
+)
+
+// resourceBlock is the text of a resource of a lock file, from the
+// comments right above its [[Resource]] header to the next resource.
+type resourceBlock struct {
+	lines    []string
+	header   int
+	resource Resource
+}
+
+// lockText is the text of a lock file split into the resources it
+// defines, so that it can be saved again without losing its comments and
+// formatting.
+type lockText struct {
+	preamble string
+	version  int
+	blocks   []resourceBlock
+}
+
+// parseLockText splits content, whose resources were decoded as conf, into
+// resource blocks. It returns nil if the blocks do not match conf, e.g.
+// when resources are written as inline tables.
+func parseLockText(content string, conf config) *lockText {
+	lines := strings.SplitAfter(content, "\n")
+	headers := []int{}
+	delim := ""
+	for i, line := range lines {
+		if delim == "" && isResourceHeader(line) {
+			headers = append(headers, i)
+		}
+		delim = multilineStringDelim(line, delim)
+	}
+	if len(headers) != len(conf.Resource) {
+		return nil
+	}
+	starts := []int{}
+	for i, h := range headers {
+		start := h
+		// Comments right above a header describe its resource.
+		for start > 0 && isComment(lines[start-1]) && (i == 0 || start-1 > headers[i-1]) {
+			start--
+		}
+		starts = append(starts, start)
+	}
+	text := &lockText{preamble: strings.Join(lines, "")}
+	if len(starts) > 0 {
+		text.preamble = strings.Join(lines[:starts[0]], "")
+	}
+	var preamble config
+	if toml.Unmarshal([]byte(text.preamble), &preamble) != nil || len(preamble.Resource) != 0 || preamble.Version != conf.Version {
+		return nil
+	}
+	text.version = preamble.Version
+	for i, start := range starts {
+		end := len(lines)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		block := resourceBlock{lines: lines[start:end], header: headers[i] - start}
+		var c config
+		err := toml.Unmarshal([]byte(strings.Join(block.lines, "")), &c)
+		if err != nil || len(c.Resource) != 1 || !reflect.DeepEqual(c.Resource[0], conf.Resource[i]) {
+			return nil
+		}
+		block.resource = c.Resource[0]
+		text.blocks = append(text.blocks, block)
+	}
+	return text
+}
+
+// isResourceHeader returns true if line is a [[Resource]] header.
+func isResourceHeader(line string) bool {
+	line, _, _ = strings.Cut(line, COMMENT_PREFIX)
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "[[") || !strings.HasSuffix(line, "]]") {
+		return false
+	}
+	return strings.TrimSpace(line[2:len(line)-2]) == "Resource"
+}
+
+// isComment returns true if line only holds a comment.
+func isComment(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), COMMENT_PREFIX)
+}
+
+// multilineStringDelim returns the delimiter of the multi-line string
+// still open at the end of line, given the one open at its start.
+func multilineStringDelim(line string, delim string) string {
+	for {
+		if delim != "" {
+			i := strings.Index(line, delim)
+			if i < 0 {
+				return delim
+			}
+			line, delim = line[i+len(delim):], ""
+			continue
+		}
+		i, j := strings.Index(line, `"""`), strings.Index(line, `'''`)
+		switch {
+		case i < 0 && j < 0:
+			return ""
+		case j < 0 || (i >= 0 && i < j):
+			line, delim = line[i+3:], `"""`
+		default:
+			line, delim = line[j+3:], `'''`
+		}
+	}
+}
+
+// render returns the text of a lock file holding conf. The text of the
+// resources already present is kept, only the lines of their modified
+// settings are changed, and the new ones are appended.
+func (t *lockText) render(conf config) (string, error) {
+	blocks := map[string]resourceBlock{}
+	for _, b := range t.blocks {
+		blocks[b.resource.Urls[0]] = b
+	}
+	var sb strings.Builder
+	if conf.Version != t.version {
+		sb.WriteString(setPreambleVersion(t.preamble, conf.Version))
+	} else {
+		sb.WriteString(t.preamble)
+	}
+	for _, r := range conf.Resource {
+		var text string
+		var err error
+		b, ok := blocks[r.Urls[0]]
+		switch {
+		case !ok:
+			text, err = marshalResource(r)
+			// Separate new resources from the previous ones.
+			if sb.Len() > 0 && !strings.HasSuffix(sb.String(), "\n\n") {
+				sb.WriteString("\n")
+			}
+		case reflect.DeepEqual(b.resource, r):
+			text = strings.Join(b.lines, "")
+		default:
+			text, err = b.edit(r)
+		}
+		if err != nil {
+			return "", err
+		}
+		if sb.Len() > 0 && !strings.HasSuffix(sb.String(), "\n") {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(text)
+	}
+	return sb.String(), nil
+}
+
+// setPreambleVersion returns the preamble of a lock file with the given
+// schema version. The version is set below the comments at the top of the
+// lock file if it was not set yet.
+func setPreambleVersion(preamble string, version int) string {
+	lines := strings.SplitAfter(preamble, "\n")
+	setting := fmt.Sprintf("Version = %d\n", version)
+	insert := 0
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") {
+			break
+		}
+		name, _, ok := strings.Cut(trimmed, "=")
+		if ok && strings.TrimSpace(name) == "Version" {
+			lines[i] = setting
+			return strings.Join(lines, "")
+		}
+		if insert == i && isComment(line) {
+			insert = i + 1
+		}
+	}
+	head := strings.Join(lines[:insert], "")
+	if head != "" && !strings.HasSuffix(head, "\n") {
+		head += "\n"
+	}
+	tail := strings.Join(lines[insert:], "")
+	if strings.TrimSpace(tail) == "" {
+		// Separate the version from the resources.
+		tail = "\n"
+	}
+	return head + setting + tail
+}
+
+// edit returns the text of the block with the settings of r. The lines of
+// the modified scalar settings are replaced when possible; otherwise the
+// resource is written again below the comments of the block.
+func (b *resourceBlock) edit(r Resource) (string, error) {
+	lines := slices.Clone(b.lines)
+	old, updated := reflect.ValueOf(b.resource), reflect.ValueOf(r)
+	for i := 0; i < old.NumField(); i++ {
+		if reflect.DeepEqual(old.Field(i).Interface(), updated.Field(i).Interface()) {
+			continue
+		}
+		name := old.Type().Field(i).Name
+		line := b.settingLine(lines, name)
+		kind := updated.Field(i).Kind()
+		if line < 0 || (kind != reflect.String && kind != reflect.Int && kind != reflect.Bool) {
+			return b.rewrite(r)
+		}
+		indent := lines[line][:len(lines[line])-len(strings.TrimLeft(lines[line], " \t"))]
+		comment := inlineComment(lines[line])
+		if updated.Field(i).IsZero() {
+			lines[line] = ""
+			if comment != "" {
+				// The comment of a removed setting is kept on its own.
+				lines[line] = indent + strings.TrimLeft(comment, " \t") + "\n"
+			}
+			continue
+		}
+		setting, err := toml.Marshal(map[string]any{name: updated.Field(i).Interface()})
+		if err != nil {
+			return "", err
+		}
+		lines[line] = indent + strings.TrimSuffix(string(setting), "\n") + comment + "\n"
+	}
+	return strings.Join(lines, ""), nil
+}
+
+// settingLine returns the index of the line of lines setting the given key
+// of the resource itself, or -1 if there is not exactly one.
+func (b *resourceBlock) settingLine(lines []string, key string) int {
+	found := -1
+	delim := ""
+	for i := b.header + 1; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if delim == "" {
+			if strings.HasPrefix(line, "[") {
+				// Sub-tables of the resource.
+				break
+			}
+			name, _, ok := strings.Cut(line, "=")
+			if ok && strings.TrimSpace(name) == key {
+				if found >= 0 {
+					return -1
+				}
+				found = i
+			}
+		}
+		delim = multilineStringDelim(lines[i], delim)
+	}
+	if found >= 0 && multilineStringDelim(lines[found], "") != "" {
+		// The setting spans several lines.
+		return -1
+	}
+	return found
+}
+
+// inlineComment returns the comment ending the single-line setting line,
+// along with the spaces before it, or "" if there is none.
+func inlineComment(line string) string {
+	line = strings.TrimRight(line, "\r\n")
+	quote := byte(0)
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == '"' && c == '\\':
+			// Escaped characters of basic strings.
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && c == COMMENT_PREFIX[0]:
+			start := len(strings.TrimRight(line[:i], " \t"))
+			return line[start:]
+		}
+	}
+	return ""
+}
+
+// rewrite returns the comments of the block followed by r.
+func (b *resourceBlock) rewrite(r Resource) (string, error) {
+	text, err := marshalResource(r)
+	if err != nil {
+		return "", err
+	}
+	trailer := ""
+	for i := len(b.lines) - 1; i > b.header && strings.TrimSpace(b.lines[i]) == ""; i-- {
+		trailer += b.lines[i]
+	}
+	return strings.Join(b.lines[:b.header], "") + text + trailer, nil
+}
+
+// marshalResource returns the text of a lock file holding only r.
+func marshalResource(r Resource) (string, error) {
+	text, err := toml.Marshal(config{Resource: []Resource{r}})
+	if err != nil {
+		return "", err
+	}
+	return string(text), nil
+}
@@ -0,0 +1,154 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"os"
+	"testing"
+
+	"github.com/cisco-open/grabit/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const commentedLock = `# Third-party assets of the build.
+
+# Pinned until the 2.0 API migration.
+[[Resource]]
+  Urls = ['http://localhost:123456/a.html'] # primary mirror
+  Integrity = 'sha256-a'
+  Tags = ['tag']
+
+[[Resource]]
+  Urls = ['http://localhost:123456/b.tar.gz']
+  Integrity = 'sha256-b'
+  Extract = true
+
+  [Resource.Manifest]
+  'b/file' = 'sha256-f'
+
+# Deprecated.
+[[Resource]]
+  Urls = ['http://localhost:123456/c.html']
+  Integrity = 'sha256-c'
+  PublicKey = '''
+[[Resource]]
+'''
+`
+
+func saveAndRead(t *testing.T, lock *Lock) string {
+	err := lock.Save()
+	require.Nil(t, err)
+	content, err := os.ReadFile(lock.path)
+	require.Nil(t, err)
+	return string(content)
+}
+
+func TestLockSaveKeepsComments(t *testing.T) {
+	path := test.TmpFile(t, commentedLock)
+	lock, err := NewLock(path, false)
+	require.Nil(t, err)
+	require.NotNil(t, lock.text)
+	assert.Equal(t, 3, len(lock.text.blocks))
+	assert.Equal(t, commentedLock, saveAndRead(t, lock))
+
+	lock.conf.Resource[0].Integrity = "sha256-new"
+	lock.conf.Resource[0].Comment = "Pinned"
+	lock.conf.Resource[0].Tags = nil
+	lock.conf.Resource[1].Manifest = map[string]string{"b/file": "sha256-g"}
+	lock.conf.Resource = append(lock.conf.Resource[:2], Resource{Urls: []string{"http://localhost:123456/d.html"}, Integrity: "sha256-d"})
+	assert.Equal(t, `# Third-party assets of the build.
+
+# Pinned until the 2.0 API migration.
+[[Resource]]
+Urls = ['http://localhost:123456/a.html']
+Integrity = 'sha256-new'
+Comment = 'Pinned'
+
+[[Resource]]
+Urls = ['http://localhost:123456/b.tar.gz']
+Integrity = 'sha256-b'
+Extract = true
+
+[Resource.Manifest]
+'b/file' = 'sha256-g'
+
+[[Resource]]
+Urls = ['http://localhost:123456/d.html']
+Integrity = 'sha256-d'
+`, saveAndRead(t, lock))
+}
+
+func TestLockSaveEditsSettingLines(t *testing.T) {
+	path := test.TmpFile(t, commentedLock)
+	lock, err := NewLock(path, false)
+	require.Nil(t, err)
+	lock.conf.Resource[1].Integrity = "sha256-new"
+	lock.conf.Resource[1].Extract = false
+	lock.conf.Resource[2].Integrity = "sha256-it's"
+	expected := `# Third-party assets of the build.
+
+# Pinned until the 2.0 API migration.
+[[Resource]]
+  Urls = ['http://localhost:123456/a.html'] # primary mirror
+  Integrity = 'sha256-a'
+  Tags = ['tag']
+
+[[Resource]]
+  Urls = ['http://localhost:123456/b.tar.gz']
+  Integrity = 'sha256-new'
+
+  [Resource.Manifest]
+  'b/file' = 'sha256-f'
+
+# Deprecated.
+[[Resource]]
+  Urls = ['http://localhost:123456/c.html']
+  Integrity = "sha256-it's"
+  PublicKey = '''
+[[Resource]]
+'''
+`
+	assert.Equal(t, expected, saveAndRead(t, lock))
+
+	lock, err = NewLock(path, false)
+	require.Nil(t, err)
+	assert.Equal(t, "sha256-it's", lock.conf.Resource[2].Integrity)
+	assert.Equal(t, expected, saveAndRead(t, lock))
+}
+
+func TestLockSaveKeepsInlineComments(t *testing.T) {
+	path := test.TmpFile(t, `[[Resource]]
+Urls = ['http://localhost:123456/a.html']
+Integrity = 'sha256-a' # from release notes
+Filename = "a # b.html"	# renamed
+Comment = 'Pinned' # until 2.0
+`)
+	lock, err := NewLock(path, false)
+	require.Nil(t, err)
+	lock.conf.Resource[0].Integrity = "sha256-new"
+	lock.conf.Resource[0].Filename = "a.html"
+	lock.conf.Resource[0].Comment = ""
+	assert.Equal(t, `[[Resource]]
+Urls = ['http://localhost:123456/a.html']
+Integrity = 'sha256-new' # from release notes
+Filename = 'a.html'	# renamed
+# until 2.0
+`, saveAndRead(t, lock))
+	assert.Equal(t, "", inlineComment(`Integrity = "sha256-\"#"`))
+}
+
+func TestLockSaveUnknownLayout(t *testing.T) {
+	path := test.TmpFile(t, `# Inline tables.
+Resource = [{Urls = ['http://localhost:123456/a.html'], Integrity = 'sha256-a'}]
+`)
+	lock, err := NewLock(path, false)
+	require.Nil(t, err)
+	assert.Nil(t, lock.text)
+	assert.Equal(t, `[[Resource]]
+Urls = ['http://localhost:123456/a.html']
+Integrity = 'sha256-a'
+`, saveAndRead(t, lock))
+	assert.NotNil(t, lock.text)
+}
@@ -0,0 +1,161 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// computeManifest returns the integrity of every regular file below dir,
+// keyed by their slash-separated path relative to dir.
+func computeManifest(dir string, algo string) (map[string]string, error) {
+	manifest := map[string]string{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		integrity, err := getIntegrityFromFile(path, algo)
+		if err != nil {
+			return err
+		}
+		manifest[filepath.ToSlash(rel)] = integrity
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// manifestOfArchive extracts the archive file as the resource would be
+// and returns the manifest of its content.
+func (l *Resource) manifestOfArchive(archive string, algo string) (map[string]string, error) {
+	dir, err := os.MkdirTemp("", "grabit-manifest-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	err = extractArchive(archive, dir, l.StripComponents)
+	if err != nil {
+		return nil, err
+	}
+	return computeManifest(dir, algo)
+}
+
+// ManifestDiff lists the files of an extracted tree that differ from the
+// manifest of a resource.
+type ManifestDiff struct {
+	Added    []string
+	Removed  []string
+	Modified []string
+}
+
+// Empty returns true if the extracted tree matches the manifest.
+func (d *ManifestDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+// isSubdirectory returns true if the relative path d names a directory
+// strictly below the one it is relative to. Paths like "." or "a/.." are
+// rejected as they name the directory itself.
+func isSubdirectory(d string) bool {
+	return filepath.IsLocal(d) && filepath.Clean(d) != "."
+}
+
+// extractDir returns the directory the resource is extracted to.
+func (l *Resource) extractDir(dir string) (string, error) {
+	if l.ExtractDir == "" {
+		return dir, nil
+	}
+	if !filepath.IsLocal(l.ExtractDir) {
+		return "", fmt.Errorf("extraction directory '%s' of '%s' is outside of '%s'", l.ExtractDir, l.Urls[0], dir)
+	}
+	if !isSubdirectory(l.ExtractDir) {
+		return "", fmt.Errorf("extraction directory '%s' of '%s' must be below '%s': leave it empty to extract into '%s'", l.ExtractDir, l.Urls[0], dir, dir)
+	}
+	return filepath.Join(dir, l.ExtractDir), nil
+}
+
+// VerifyExtracted compares the files extracted in dir with the manifest
+// of the resource. The whole ExtractDir is compared when set; otherwise,
+// as dir holds other files, only the top-level directories of the
+// manifest are.
+func (l *Resource) VerifyExtracted(dir string) (ManifestDiff, error) {
+	diff := ManifestDiff{Added: []string{}, Removed: []string{}, Modified: []string{}}
+	algo, err := getAlgoFromIntegrity(l.Integrity)
+	if err != nil {
+		return diff, err
+	}
+	extractDir, err := l.extractDir(dir)
+	if err != nil {
+		return diff, err
+	}
+	roots := []string{"."}
+	if l.ExtractDir == "" {
+		roots = []string{}
+		for name := range l.Manifest {
+			top, _, found := strings.Cut(name, "/")
+			if found && !slices.Contains(roots, top) {
+				roots = append(roots, top)
+			}
+		}
+	}
+	found := map[string]string{}
+	for _, root := range roots {
+		tree, err := computeManifest(filepath.Join(extractDir, root), algo)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return diff, err
+		}
+		for name, integrity := range tree {
+			found[filepath.ToSlash(filepath.Join(root, name))] = integrity
+		}
+	}
+	for name := range l.Manifest {
+		if _, ok := found[name]; ok {
+			continue
+		}
+		// Top-level files are not part of any walked directory.
+		computed, err := getIntegrityFromFile(filepath.Join(extractDir, filepath.FromSlash(name)), algo)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return diff, err
+		}
+		found[name] = computed
+	}
+	for name, integrity := range l.Manifest {
+		computed, ok := found[name]
+		if !ok {
+			diff.Removed = append(diff.Removed, name)
+		} else if computed != integrity {
+			diff.Modified = append(diff.Modified, name)
+		}
+	}
+	for name := range found {
+		if _, ok := l.Manifest[name]; !ok {
+			diff.Added = append(diff.Added, name)
+		}
+	}
+	slices.Sort(diff.Added)
+	slices.Sort(diff.Removed)
+	slices.Sort(diff.Modified)
+	return diff, nil
+}
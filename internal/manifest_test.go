@@ -0,0 +1,103 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cisco-open/grabit/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewResourceWithManifest(t *testing.T) {
+	content := test.Archive(t, FormatTarGz, testArchiveEntries)
+	port := test.TestHttpHandler(content, t)
+	template := Resource{
+		Urls:            []string{fmt.Sprintf("http://localhost:%d/pkg.tar.gz", port)},
+		Extract:         true,
+		StripComponents: 1,
+	}
+	resource, err := NewResource(template, AddOptions{Algo: "sha256", Manifest: true})
+	require.Nil(t, err)
+	assert.Equal(t, test.GetSha256Integrity(content), resource.Integrity)
+	// Symbolic links are not part of the manifest.
+	assert.Equal(t, map[string]string{
+		"README":   test.GetSha256Integrity("readme"),
+		"bin/tool": test.GetSha256Integrity("#!/bin/sh"),
+	}, resource.Manifest)
+
+	template.Extract = false
+	_, err = NewResource(template, AddOptions{Algo: "sha256", Manifest: true})
+	assert.ErrorContains(t, err, "only be computed for extracted resources")
+}
+
+func TestVerifyExtracted(t *testing.T) {
+	content := test.Archive(t, FormatTarGz, testArchiveEntries)
+	port := test.TestHttpHandler(content, t)
+	for _, extractDir := range []string{"", "pkg"} {
+		t.Run(fmt.Sprintf("ExtractDir='%s'", extractDir), func(t *testing.T) {
+			resource, err := NewResource(Resource{
+				Urls:       []string{fmt.Sprintf("http://localhost:%d/pkg.tar.gz", port)},
+				Extract:    true,
+				ExtractDir: extractDir,
+			}, AddOptions{Algo: "sha256", Manifest: true})
+			require.Nil(t, err)
+			dir := test.TmpDir(t)
+			err = resource.Download(dir, 0644, context.Background(), DownloadOptions{})
+			require.Nil(t, err)
+			// Files of other resources are not reported as added.
+			err = os.WriteFile(filepath.Join(dir, "other"), []byte("other"), 0644)
+			require.Nil(t, err)
+
+			diff, err := resource.VerifyExtracted(dir)
+			require.Nil(t, err)
+			assert.True(t, diff.Empty())
+			res := resource.Verify(dir)
+			assert.Equal(t, VerifyOK, res.Status)
+
+			root := filepath.Join(dir, extractDir, "pkg-1.0")
+			err = os.WriteFile(filepath.Join(root, "README"), []byte("tampered"), 0644)
+			require.Nil(t, err)
+			err = os.Remove(filepath.Join(root, "bin", "tool"))
+			require.Nil(t, err)
+			err = os.WriteFile(filepath.Join(root, "bin", "added"), []byte("added"), 0644)
+			require.Nil(t, err)
+			diff, err = resource.VerifyExtracted(dir)
+			require.Nil(t, err)
+			assert.Equal(t, []string{"pkg-1.0/bin/added"}, diff.Added)
+			assert.Equal(t, []string{"pkg-1.0/bin/tool"}, diff.Removed)
+			assert.Equal(t, []string{"pkg-1.0/README"}, diff.Modified)
+
+			res = resource.Verify(dir)
+			assert.Equal(t, VerifyModified, res.Status)
+			assert.ErrorContains(t, res.Err, "1 added, 1 removed, 1 modified")
+			assert.Equal(t, &diff, res.Diff)
+		})
+	}
+}
+
+func TestVerifyExtractedTopLevelFiles(t *testing.T) {
+	resource := Resource{
+		Urls:      []string{"http://localhost:123456/pkg.zip"},
+		Integrity: test.GetSha256Integrity("archive"),
+		Extract:   true,
+		Manifest: map[string]string{
+			"a": test.GetSha256Integrity("a"),
+			"b": test.GetSha256Integrity("b"),
+		},
+	}
+	dir := test.TmpDir(t)
+	err := os.WriteFile(filepath.Join(dir, "a"), []byte("modified"), 0644)
+	require.Nil(t, err)
+	diff, err := resource.VerifyExtracted(dir)
+	require.Nil(t, err)
+	assert.Empty(t, diff.Added)
+	assert.Equal(t, []string{"b"}, diff.Removed)
+	assert.Equal(t, []string{"a"}, diff.Modified)
+}
@@ -7,12 +7,18 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
+	"io"
 	"net/http"
 	"net/url"
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/rs/zerolog/log"
 
@@ -26,6 +32,61 @@ type Resource struct {
 	Tags                []string `toml:",omitempty"`
 	Filename            string   `toml:",omitempty"`
 	ArtifactoryCacheURL string   `toml:",omitempty"`
+	// Comment describes the resource, e.g. why it is pinned. It is not
+	// used by grabit.
+	Comment string `toml:",omitempty"`
+	// Retries and RetryBackoff override the retry policy of downloads.
+	Retries      int    `toml:",omitempty"`
+	RetryBackoff string `toml:",omitempty"`
+	// Extract the downloaded archive into ExtractDir, relative to the
+	// download directory, removing the first StripComponents elements of
+	// the entry names.
+	Extract         bool   `toml:",omitempty"`
+	StripComponents int    `toml:",omitempty"`
+	ExtractDir      string `toml:",omitempty"`
+	// Manifest holds the integrity of every extracted file, keyed by its
+	// path relative to the extraction directory.
+	Manifest map[string]string `toml:",omitempty"`
+	// SignatureUrl is the url of a detached signature of the resource.
+	// OpenPGP signatures, the default SignatureFormat, are made by one of
+	// the keys of the armored PublicKey or of the Keyring file; minisign
+	// and signify ones by the PublicKey. A relative Keyring path is
+	// relative to the directory of the lock file.
+	SignatureUrl    string `toml:",omitempty"`
+	SignatureFormat string `toml:",omitempty"`
+	PublicKey       string `toml:",multiline,omitempty"`
+	Keyring         string `toml:",omitempty"`
+	// SigstoreBundleUrl is the url of a Sigstore bundle of the resource,
+	// holding its signature, the certificate of the signer and the proof of
+	// its inclusion in a transparency log. It is verified offline against
+	// the SigstoreTrustedRoot file and must have been signed by
+	// SigstoreIdentity as authenticated by SigstoreIssuer. A relative
+	// SigstoreTrustedRoot path is relative to the directory of the lock
+	// file.
+	SigstoreBundleUrl   string `toml:",omitempty"`
+	SigstoreTrustedRoot string `toml:",omitempty"`
+	SigstoreIdentity    string `toml:",omitempty"`
+	SigstoreIssuer      string `toml:",omitempty"`
+}
+
+// AddOptions holds the settings used when adding a resource that are not
+// stored in the lock file.
+type AddOptions struct {
+	Algo string
+	// Integrity is the known integrity of the resource, as returned by
+	// ParseIntegrity. The resource is not downloaded unless VerifyNow is
+	// true, in which case the downloaded content must match it.
+	Integrity string
+	VerifyNow bool
+	// ChecksumUrl is the url of a checksum file listing the resource. The
+	// downloaded content must match its checksum.
+	ChecksumUrl string
+	// Manifest requests the computation of the manifest of the extracted
+	// files of the resource.
+	Manifest bool
+	// BaseDir is the directory relative Keyring and SigstoreTrustedRoot
+	// paths are relative to, the current directory if empty.
+	BaseDir string
 }
 
 const GRABIT_ARTIFACTORY_TOKEN_ENV_VAR = "GRABIT_ARTIFACTORY_TOKEN"
@@ -42,21 +103,102 @@ func getArtifactoryToken() string {
 }
 
 func NewResourceFromUrl(urls []string, algo string, tags []string, filename string, ArtifactoryCacheURL string) (*Resource, error) {
+	return NewResource(Resource{Urls: urls, Tags: tags, Filename: filename, ArtifactoryCacheURL: ArtifactoryCacheURL}, AddOptions{Algo: algo})
+}
+
+// NewResource returns a copy of the template resource with its integrity
+// computed from the content of its first url.
+func NewResource(template Resource, opts AddOptions) (*Resource, error) {
+	urls := template.Urls
 	if len(urls) < 1 {
 		return nil, fmt.Errorf("empty url list")
 	}
+	if opts.Manifest && !template.Extract {
+		return nil, fmt.Errorf("a manifest can only be computed for extracted resources")
+	}
+	algo := opts.Algo
+	if template.ExtractDir != "" && !isSubdirectory(template.ExtractDir) {
+		return nil, fmt.Errorf("extraction directory '%s' must be a relative path below the download directory", template.ExtractDir)
+	}
+	err := template.checkSignatureSettings()
+	if err != nil {
+		return nil, err
+	}
+	err = template.checkSigstoreSettings()
+	if err != nil {
+		return nil, err
+	}
+	if opts.Integrity != "" && opts.ChecksumUrl != "" {
+		return nil, fmt.Errorf("a known integrity and a checksum file cannot be used together")
+	}
+	if opts.Integrity != "" {
+		algo, err = getAlgoFromIntegrity(opts.Integrity)
+		if err != nil {
+			return nil, err
+		}
+		if !opts.VerifyNow {
+			return newResourceWithIntegrity(template, opts)
+		}
+	}
 	url := urls[0]
 	ctx := context.Background()
-	path, err := GetUrltoTempFile(url, "", ctx)
+	checksum := ""
+	if opts.ChecksumUrl != "" {
+		checksum, err = fetchChecksum(opts.ChecksumUrl, template.checksumFileNames(), ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	hasher, err := newHasher(algo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute resource integrity: %s", err)
+	}
+	path, err := GetUrltoTempFile(url, "", hasher, ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get url: %s", err)
 	}
 	defer os.Remove(path)
-	integrity, err := getIntegrityFromFile(path, algo)
+	if template.Extract {
+		// Fail early rather than on every download.
+		_, err := archiveFormat(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot extract '%s': %w", url, err)
+		}
+	}
+	resource := template
+	resource.Integrity = integrityFromHasher(algo, hasher)
+	if opts.Integrity != "" {
+		err = checkIntegrity(resource.Integrity, opts.Integrity, url)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if checksum != "" {
+		checksumAlgo, _ := getAlgoFromIntegrity(checksum)
+		computed, err := getIntegrityFromFile(path, checksumAlgo)
+		if err != nil {
+			return nil, err
+		}
+		err = checkIntegrity(computed, checksum, url)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' does not match its checksum from '%s': %w", url, opts.ChecksumUrl, err)
+		}
+	}
+	err = resource.verifySignature(path, opts.BaseDir, ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to compute resource integrity: %s", err)
+		return nil, err
+	}
+	err = resource.verifySigstoreBundle(path, opts.BaseDir, ctx)
+	if err != nil {
+		return nil, err
+	}
+	resource.Manifest = nil
+	if opts.Manifest {
+		resource.Manifest, err = resource.manifestOfArchive(path, algo)
+		if err != nil {
+			return nil, fmt.Errorf("cannot compute manifest of '%s': %w", url, err)
+		}
 	}
-	resource := &Resource{Urls: urls, Integrity: integrity, Tags: tags, Filename: filename, ArtifactoryCacheURL: ArtifactoryCacheURL}
 	// If cache URL is provided, upload file to Artifactory.
 	if resource.ArtifactoryCacheURL != "" {
 		err := resource.AddToCache(path)
@@ -64,7 +206,26 @@ func NewResourceFromUrl(urls []string, algo string, tags []string, filename stri
 			return nil, fmt.Errorf("failed to upload to cache: %s", err)
 		}
 	}
-	return resource, nil
+	return &resource, nil
+}
+
+// newResourceWithIntegrity returns a copy of the template resource with
+// the given known integrity, without downloading it.
+func newResourceWithIntegrity(template Resource, opts AddOptions) (*Resource, error) {
+	// These need the content of the resource.
+	if opts.Manifest {
+		return nil, fmt.Errorf("computing a manifest requires downloading the resource")
+	}
+	if template.ArtifactoryCacheURL != "" {
+		return nil, fmt.Errorf("uploading to the Artifactory cache requires downloading the resource")
+	}
+	if template.SignatureUrl != "" || template.SigstoreBundleUrl != "" {
+		return nil, fmt.Errorf("verifying the signature requires downloading the resource")
+	}
+	resource := template
+	resource.Integrity = opts.Integrity
+	resource.Manifest = nil
+	return &resource, nil
 }
 
 func (l *Resource) getCacheFullURL() string {
@@ -111,68 +272,335 @@ func (l *Resource) Delete() error {
 	return nil
 }
 
-// getUrl downloads the given resource and returns the path to it.
-func getUrl(u string, fileName string, bearer string, ctx context.Context) (string, error) {
+// getUrl downloads the given resource and returns the path to it. The
+// downloaded content is also written to hasher when it is not nil so that
+// its integrity can be computed without reading the file again.
+// When resume is true, a partial download left at fileName by an earlier
+// attempt is resumed if the server supports range requests.
+func getUrl(u string, fileName string, bearer string, hasher hash.Hash, resume bool, ctx context.Context) (string, error) {
 	_, err := url.Parse(u)
 	if err != nil {
 		return "", fmt.Errorf("invalid url '%s': %s", u, err)
 	}
 	log.Debug().Str("URL", u).Msg("Downloading")
 
+	offset := int64(0)
+	validator := ""
+	if resume {
+		offset, validator = getPartialDownload(fileName)
+	}
+	err = fetchToFile(u, fileName, bearer, hasher, resume, offset, validator, ctx)
+	if offset > 0 && (requests.HasStatusErr(err, http.StatusRequestedRangeNotSatisfiable) || errors.Is(err, errCannotResume)) {
+		log.Debug().Str("URL", u).Msg("Cannot resume download, starting over")
+		err = fetchToFile(u, fileName, bearer, hasher, resume, 0, "", ctx)
+	}
+	if err != nil {
+		if resume && ctx.Err() != nil {
+			// Cancelled downloads are not resumed.
+			removeTempFile(fileName)
+		}
+		return "", fmt.Errorf("failed to download '%s': %w", u, err)
+	}
+	if resume {
+		os.Remove(validatorFileName(fileName))
+	}
+
+	return fileName, nil
+}
+
+// errCannotResume is returned when the response to a range request does
+// not continue the partial download.
+var errCannotResume = errors.New("cannot resume download")
+
+// fetchToFile downloads the given resource to fileName. If offset is not
+// zero, only the content after offset is requested provided the resource
+// still matches validator; the server may send the whole content anyway.
+func fetchToFile(u string, fileName string, bearer string, hasher hash.Hash, resume bool, offset int64, validator string, ctx context.Context) error {
 	req := requests.
 		URL(u).
 		Transport(noCompressionTransport).
 		Header("Accept", "*/*").
-		ToFile(fileName)
+		Handle(toFileWithHasher(fileName, hasher, resume, offset))
 
 	if bearer != "" {
 		req.Header("Authorization", fmt.Sprintf("Bearer %s", bearer))
 	}
+	if offset > 0 {
+		log.Debug().Str("URL", u).Int64("offset", offset).Msg("Resuming download")
+		req.Header("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header("If-Range", validator)
+		req.CheckStatus(http.StatusOK, http.StatusPartialContent)
+	}
+	return req.Fetch(ctx)
+}
 
-	err = req.Fetch(ctx)
-	if err != nil {
-		return "", fmt.Errorf("failed to download '%s': %s", u, err)
+// validatorFileName returns the name of the file storing the validator
+// (ETag or Last-Modified) of the partial download at fileName.
+func validatorFileName(fileName string) string {
+	return fileName + ".range"
+}
+
+// getPartialDownload returns the size and the validator of the partial
+// download at fileName. A zero size is returned if it cannot be resumed.
+func getPartialDownload(fileName string) (int64, string) {
+	stat, err := os.Stat(fileName)
+	if err != nil || stat.Size() == 0 {
+		return 0, ""
+	}
+	validator, err := os.ReadFile(validatorFileName(fileName))
+	if err != nil || len(validator) == 0 {
+		return 0, ""
 	}
+	return stat.Size(), string(validator)
+}
 
-	return fileName, nil
+// getValidator returns the value to use in an If-Range header to resume
+// the download of the given response, if any. Weak ETags cannot be used.
+func getValidator(res *http.Response) string {
+	if res.Header.Get("Accept-Ranges") == "none" {
+		return ""
+	}
+	etag := res.Header.Get("ETag")
+	if etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return res.Header.Get("Last-Modified")
+}
+
+// toFileWithHasher writes the response body at the provided file path and
+// to hasher, if not nil. Partial content responses are appended to the
+// first offset bytes already present in the file.
+func toFileWithHasher(fileName string, hasher hash.Hash, resume bool, offset int64) requests.ResponseHandler {
+	return func(res *http.Response) error {
+		var f *os.File
+		var err error
+		if res.StatusCode == http.StatusPartialContent {
+			contentRange := res.Header.Get("Content-Range")
+			if !strings.HasPrefix(contentRange, fmt.Sprintf("bytes %d-", offset)) {
+				// Resuming would fail again the same way.
+				removeTempFile(fileName)
+				return fmt.Errorf("%w: unexpected Content-Range '%s' when resuming at offset %d", errCannotResume, contentRange, offset)
+			}
+			f, err = os.OpenFile(fileName, os.O_RDWR, 0)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			// The integrity is computed over the complete file.
+			if hasher != nil {
+				_, err = io.CopyN(hasher, f, offset)
+				if err != nil {
+					return err
+				}
+			}
+			_, err = f.Seek(offset, io.SeekStart)
+			if err != nil {
+				return err
+			}
+			err = f.Truncate(offset)
+			if err != nil {
+				return err
+			}
+		} else {
+			if resume {
+				// Keep what is needed to resume this download later.
+				validator := getValidator(res)
+				if validator != "" {
+					err = os.WriteFile(validatorFileName(fileName), []byte(validator), 0644)
+				} else {
+					err = os.Remove(validatorFileName(fileName))
+				}
+				if err != nil && !os.IsNotExist(err) {
+					return err
+				}
+			}
+			f, err = os.Create(fileName)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+		}
+		var w io.Writer = f
+		if hasher != nil {
+			w = io.MultiWriter(f, hasher)
+		}
+		_, err = io.Copy(w, res.Body)
+		if err != nil {
+			if res.Request.Context().Err() != nil {
+				// Do not leave partial content behind when cancelled.
+				f.Close()
+				removeTempFile(fileName)
+			}
+			return err
+		}
+		return f.Close()
+	}
+}
+
+// removeTempFile removes the partial download at fileName along with the
+// information needed to resume it.
+func removeTempFile(fileName string) {
+	os.Remove(fileName)
+	os.Remove(validatorFileName(fileName))
 }
 
 // GetUrlToDir downloads the given resource to the given directory and returns the path to it.
-func GetUrlToDir(u string, targetDir string, bearer string, ctx context.Context) (string, error) {
-	// create temporary name in the target directory.
+func GetUrlToDir(u string, targetDir string, bearer string, hasher hash.Hash, ctx context.Context) (string, error) {
+	return getUrl(u, tempFileName(u, targetDir), bearer, hasher, true, ctx)
+}
+
+// tempFileName returns the temporary name in the target directory used
+// while downloading the given url.
+func tempFileName(u string, targetDir string) string {
 	h := sha256.New()
 	h.Write([]byte(u))
-	fileName := filepath.Join(targetDir, fmt.Sprintf(".%s", hex.EncodeToString(h.Sum(nil))))
-	return getUrl(u, fileName, bearer, ctx)
+	return filepath.Join(targetDir, fmt.Sprintf(".%s", hex.EncodeToString(h.Sum(nil))))
 }
 
 // GetUrltoTempFile downloads the given resource to a temporary file and returns the path to it.
-func GetUrltoTempFile(u string, bearer string, ctx context.Context) (string, error) {
+func GetUrltoTempFile(u string, bearer string, hasher hash.Hash, ctx context.Context) (string, error) {
 	file, err := os.CreateTemp("", "prefix")
 	if err != nil {
 		log.Fatal().Err(err)
 	}
 	fileName := file.Name()
-	return getUrl(u, fileName, "", ctx)
+	return getUrl(u, fileName, "", hasher, false, ctx)
+}
+
+// DownloadOptions holds the optional settings of resource downloads.
+type DownloadOptions struct {
+	// Cache, when set, is consulted before any network access and is
+	// populated with every file whose integrity was checked.
+	Cache *Cache
+	// Offline forbids any network access: resources must be satisfied
+	// from the destination directory or the cache.
+	Offline bool
+	// Retry controls how failed downloads are retried. Resources can
+	// override it.
+	Retry RetryPolicy
+	// Jobs is the number of resources downloaded simultaneously by
+	// Lock.Download (number of CPUs if not positive).
+	Jobs int
+	// JobsPerHost, if positive, limits the number of simultaneous
+	// connections of Lock.Download to each host.
+	JobsPerHost int
+	// FailFast makes Lock.Download cancel the remaining downloads on the
+	// first failure.
+	FailFast bool
+	// BaseDir is the directory relative Keyring and SigstoreTrustedRoot
+	// paths are relative to. Lock.Download sets it to the directory of
+	// the lock file.
+	BaseDir string
+
+	hosts *hostLimiter
+}
+
+// ErrOffline is returned when a resource cannot be satisfied without
+// network access.
+var ErrOffline = errors.New("resource not available offline")
+
+// Download gets the resource into dir, checks its integrity and extracts
+// it if requested.
+func (l *Resource) Download(dir string, mode os.FileMode, ctx context.Context, opts DownloadOptions) error {
+	return l.downloadWithResult(dir, mode, ctx, opts).Err
+}
+
+// ResourceError is an error concerning the resource with the given url.
+type ResourceError struct {
+	Url string
+	Err error
 }
 
-func (l *Resource) Download(dir string, mode os.FileMode, ctx context.Context) error {
+func (e *ResourceError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ResourceError) Unwrap() error {
+	return e.Err
+}
+
+// DownloadResult describes the download of a single resource.
+type DownloadResult struct {
+	Resource Resource
+	// Path is the path of the resource in the download directory.
+	Path string
+	// Url is the url the resource was downloaded from. It is empty when
+	// the resource was already present or found in the local cache.
+	Url string
+	// Bytes is the size of the resource.
+	Bytes    int64
+	Duration time.Duration
+	// CacheHit is true when the resource was found in the local cache.
+	CacheHit bool
+	Err      error
+}
+
+// downloadWithResult downloads the resource like Download and describes
+// the outcome.
+func (l *Resource) downloadWithResult(dir string, mode os.FileMode, ctx context.Context, opts DownloadOptions) DownloadResult {
+	start := time.Now()
+	res := DownloadResult{Resource: *l}
+	res.Err = l.download(dir, mode, ctx, opts, &res)
+	if res.Err == nil && l.Extract {
+		res.Err = l.extract(res.Path, dir)
+	}
+	if res.Err == nil {
+		if stat, err := os.Stat(res.Path); err == nil {
+			res.Bytes = stat.Size()
+		}
+	}
+	res.Duration = time.Since(start)
+	return res
+}
+
+// extract extracts the archive of the resource, whose integrity was
+// checked, into its extraction directory.
+func (l *Resource) extract(archive string, dir string) error {
+	extractDir, err := l.extractDir(dir)
+	if err != nil {
+		return err
+	}
+	log.Debug().Str("URL", l.Urls[0]).Msgf("Extracting to '%s'", extractDir)
+	return extractArchive(archive, extractDir, l.StripComponents)
+}
+
+// download gets the resource into dir and records its path and origin in
+// res.
+func (l *Resource) download(dir string, mode os.FileMode, ctx context.Context, opts DownloadOptions, res *DownloadResult) error {
 	algo, err := getAlgoFromIntegrity(l.Integrity)
 	if err != nil {
 		return err
 	}
+	retry, err := l.retryPolicy(opts.Retry)
+	if err != nil {
+		return err
+	}
+	// Check if the local cache has the resource.
+	if opts.Cache != nil {
+		ok, err := l.getFromCache(dir, mode, opts.Cache)
+		if err != nil {
+			return err
+		}
+		if ok {
+			res.Path = filepath.Join(dir, l.localName(l.Urls[0]))
+			res.CacheHit = true
+			return nil
+		}
+	}
 	// Check if a cache URL exists to use Artifactory first.
-	if l.ArtifactoryCacheURL != "" {
+	if l.ArtifactoryCacheURL != "" && !opts.Offline {
 		token := getArtifactoryToken()
 		if token != "" {
 			artifactoryURL := fmt.Sprintf("%s/%s", l.ArtifactoryCacheURL, l.Integrity)
-			localName := l.Filename
-			if localName == "" {
-				localName = path.Base(l.Urls[0])
-			}
-			resPath := filepath.Join(dir, localName)
+			resPath := filepath.Join(dir, l.localName(l.Urls[0]))
 
-			tmpPath, err := getUrl(artifactoryURL, resPath, token, ctx)
+			hasher, _ := newHasher(algo)
+			release, err := opts.hosts.acquire(ctx, artifactoryURL)
+			if err != nil {
+				return err
+			}
+			tmpPath, err := getUrl(artifactoryURL, resPath, token, hasher, false, ctx)
+			release()
 			if err == nil {
 				if mode != NoFileMode {
 					err = os.Chmod(tmpPath, mode.Perm())
@@ -180,25 +608,18 @@ func (l *Resource) Download(dir string, mode os.FileMode, ctx context.Context) e
 						return fmt.Errorf("error changing target file permission: '%v'", err)
 					}
 				}
-				err = checkIntegrityFromFile(resPath, algo, l.Integrity, artifactoryURL)
+				err = checkIntegrity(integrityFromHasher(algo, hasher), l.Integrity, artifactoryURL)
 				if err != nil {
 					return fmt.Errorf("cache file at '%s' with incorrect integrity: '%v'", artifactoryURL, err)
 				}
+				l.putInCache(resPath, opts.Cache)
 			}
 			log.Warn().Msgf("Failed to download from Artifactory cache, falling back to original URL: %v\n", err)
 		}
 	}
-	ok := false
-
 	var downloadError error = nil
 	for _, u := range l.Urls {
-		localName := ""
-		if l.Filename != "" {
-			localName = l.Filename
-		} else {
-			localName = path.Base(u)
-		}
-		resPath := filepath.Join(dir, localName)
+		resPath := filepath.Join(dir, l.localName(u))
 
 		// Check if the destination file already exists and has the correct integrity.
 		_, err := os.Stat(resPath)
@@ -211,50 +632,140 @@ func (l *Resource) Download(dir string, mode os.FileMode, ctx context.Context) e
 			if err != nil {
 				return fmt.Errorf("existing file at '%s' with incorrect integrity: '%v'", resPath, err)
 			}
+			l.putInCache(resPath, opts.Cache)
 			if mode != NoFileMode {
 				err = os.Chmod(resPath, mode.Perm())
 				if err != nil {
 					return err
 				}
 			}
+			res.Path = resPath
 			return nil
 		}
 
+		if opts.Offline {
+			downloadError = fmt.Errorf("%w: '%s' is neither in '%s' nor in the local cache", ErrOffline, l.Urls[0], dir)
+			continue
+		}
+
 		// Download file in the target directory so that the call to
 		// os.Rename is atomic.
-		lpath, err := GetUrlToDir(u, dir, "", ctx)
+		var hasher hash.Hash
+		var lpath string
+		err = retry.retry(ctx, u, func() error {
+			release, err := opts.hosts.acquire(ctx, u)
+			if err != nil {
+				return err
+			}
+			defer release()
+			hasher, _ = newHasher(algo)
+			lpath, err = GetUrlToDir(u, dir, "", hasher, ctx)
+			return err
+		})
 		if err != nil {
 			downloadError = err
 			continue
 		}
-		err = checkIntegrityFromFile(lpath, algo, l.Integrity, u)
+		// The integrity was computed while downloading: no need to read
+		// the file again.
+		err = checkIntegrity(integrityFromHasher(algo, hasher), l.Integrity, u)
 		if err != nil {
+			// Do not resume from invalid content on the next run.
+			os.Remove(lpath)
+			return err
+		}
+		err = l.verifySignature(lpath, opts.BaseDir, ctx)
+		if err == nil {
+			err = l.verifySigstoreBundle(lpath, opts.BaseDir, ctx)
+		}
+		if err != nil {
+			os.Remove(lpath)
 			return err
 		}
 		err = os.Rename(lpath, resPath)
 		if err != nil {
 			return err
 		}
+		l.putInCache(resPath, opts.Cache)
 		if mode != NoFileMode {
 			err = os.Chmod(resPath, mode.Perm())
 			if err != nil {
 				return fmt.Errorf("error changing target file permission: '%v'", err)
 			}
 		}
-		ok = true
-		break
+		res.Path = resPath
+		res.Url = u
+		return nil
+	}
+	if downloadError != nil {
+		return downloadError
+	}
+	panic("no error but no file downloaded")
+}
+
+// getFromCache copies the resource from the local cache to dir unless a
+// file with the resource name is already present. It returns true if the
+// resource was found in the cache.
+func (l *Resource) getFromCache(dir string, mode os.FileMode, cache *Cache) (bool, error) {
+	resPath := filepath.Join(dir, l.localName(l.Urls[0]))
+	_, err := os.Stat(resPath)
+	if err == nil {
+		// Let the regular checks handle existing files.
+		return false, nil
+	}
+	if !os.IsNotExist(err) {
+		return false, fmt.Errorf("error checking destination file presence '%s': '%v'", resPath, err)
+	}
+	ok, err := cache.Get(l.Integrity, resPath)
+	if err != nil {
+		log.Warn().Msgf("Failed to get '%s' from local cache: %v", l.Urls[0], err)
+		return false, nil
 	}
 	if !ok {
-		if err == nil {
-			if downloadError != nil {
-				return downloadError
-			} else {
-				panic("no error but no file downloaded")
-			}
+		return false, nil
+	}
+	log.Debug().Str("URL", l.Urls[0]).Msg("Found in local cache")
+	if mode != NoFileMode {
+		err = os.Chmod(resPath, mode.Perm())
+		if err != nil {
+			return false, fmt.Errorf("error changing target file permission: '%v'", err)
 		}
-		return err
 	}
-	return nil
+	return true, nil
+}
+
+// putInCache stores the file at path, whose integrity was checked, in
+// the local cache. Failures are not fatal to the download.
+func (l *Resource) putInCache(path string, cache *Cache) {
+	if cache == nil {
+		return
+	}
+	err := cache.Put(path, l.Integrity)
+	if err != nil {
+		log.Warn().Msgf("Failed to store '%s' in local cache: %v", l.Urls[0], err)
+	}
+}
+
+// localName returns the name of the file the resource is stored as when
+// downloaded from the given url.
+func (l *Resource) localName(u string) string {
+	if l.Filename != "" {
+		return l.Filename
+	}
+	return path.Base(u)
+}
+
+// localNames returns the distinct file names the resource can be stored
+// as, one per url unless Filename is set.
+func (l *Resource) localNames() []string {
+	names := []string{}
+	for _, u := range l.Urls {
+		name := l.localName(u)
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
 }
 
 func (l *Resource) Contains(url string) bool {
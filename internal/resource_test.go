@@ -9,7 +9,10 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/cisco-open/grabit/test"
 	"github.com/stretchr/testify/assert"
@@ -54,6 +57,137 @@ func TestNewResourceFromUrl(t *testing.T) {
 	}
 }
 
+func TestGetUrlToDirComputesIntegrity(t *testing.T) {
+	content := `abcdef`
+	port := test.TestHttpHandler(content, t)
+	hasher, err := newHasher("sha256")
+	require.Nil(t, err)
+	path, err := GetUrlToDir(fmt.Sprintf("http://localhost:%d/test.html", port), test.TmpDir(t), "", hasher, context.Background())
+	require.Nil(t, err)
+	test.AssertFileContains(t, path, content)
+	assert.Equal(t, test.GetSha256Integrity(content), integrityFromHasher("sha256", hasher))
+}
+
+func TestGetUrlToDirResumesPartialDownload(t *testing.T) {
+	content := `abcdef`
+	etag := `"v1"`
+	rangeHeaders := []string{}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		rangeHeaders = append(rangeHeaders, r.Header.Get("Range"))
+		w.Header().Set("ETag", etag)
+		http.ServeContent(w, r, "test.html", time.Time{}, strings.NewReader(content))
+	}
+	port, server := test.HttpHandler(handler)
+	defer server.Close()
+	u := fmt.Sprintf("http://localhost:%d/test.html", port)
+	dir := test.TmpDir(t)
+
+	// Partial download with a matching validator: only the rest is fetched.
+	err := os.WriteFile(tempFileName(u, dir), []byte("abc"), 0644)
+	require.Nil(t, err)
+	err = os.WriteFile(validatorFileName(tempFileName(u, dir)), []byte(etag), 0644)
+	require.Nil(t, err)
+	hasher, _ := newHasher("sha256")
+	path, err := GetUrlToDir(u, dir, "", hasher, context.Background())
+	require.Nil(t, err)
+	test.AssertFileContains(t, path, content)
+	assert.Equal(t, test.GetSha256Integrity(content), integrityFromHasher("sha256", hasher))
+	assert.Equal(t, []string{"bytes=3-"}, rangeHeaders)
+	assert.NoFileExists(t, validatorFileName(path))
+
+	// Partial download with an outdated validator: everything is fetched again.
+	err = os.WriteFile(path, []byte("xyz"), 0644)
+	require.Nil(t, err)
+	err = os.WriteFile(validatorFileName(path), []byte(`"v0"`), 0644)
+	require.Nil(t, err)
+	hasher, _ = newHasher("sha256")
+	path, err = GetUrlToDir(u, dir, "", hasher, context.Background())
+	require.Nil(t, err)
+	test.AssertFileContains(t, path, content)
+	assert.Equal(t, test.GetSha256Integrity(content), integrityFromHasher("sha256", hasher))
+}
+
+func TestGetUrlToDirUnexpectedContentRange(t *testing.T) {
+	content := `abcdef`
+	etag := `"v1"`
+	rangeHeaders := []string{}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		rangeHeaders = append(rangeHeaders, r.Header.Get("Range"))
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("Range") != "" {
+			// Not the requested range.
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-%d/%d", len(content)-1, len(content)))
+			w.WriteHeader(http.StatusPartialContent)
+		}
+		w.Write([]byte(content))
+	}
+	port, server := test.HttpHandler(handler)
+	defer server.Close()
+	u := fmt.Sprintf("http://localhost:%d/test.html", port)
+	dir := test.TmpDir(t)
+	err := os.WriteFile(tempFileName(u, dir), []byte("abc"), 0644)
+	require.Nil(t, err)
+	err = os.WriteFile(validatorFileName(tempFileName(u, dir)), []byte(etag), 0644)
+	require.Nil(t, err)
+
+	// The download starts over instead of resuming.
+	hasher, _ := newHasher("sha256")
+	path, err := GetUrlToDir(u, dir, "", hasher, context.Background())
+	require.Nil(t, err)
+	test.AssertFileContains(t, path, content)
+	assert.Equal(t, test.GetSha256Integrity(content), integrityFromHasher("sha256", hasher))
+	assert.Equal(t, []string{"bytes=3-", ""}, rangeHeaders)
+}
+
+func TestResourceDownloadResumesAfterFailure(t *testing.T) {
+	content := `abcdef`
+	etag := `"v1"`
+	interrupt := true
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", etag)
+		if interrupt {
+			// Send half of the content then drop the connection.
+			interrupt = false
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			_, _ = w.Write([]byte(content[:3]))
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "test.html", time.Time{}, strings.NewReader(content))
+	}
+	port, server := test.HttpHandler(handler)
+	defer server.Close()
+	u := fmt.Sprintf("http://localhost:%d/test.html", port)
+	resource := Resource{Urls: []string{u}, Integrity: test.GetSha256Integrity(content)}
+	dir := test.TmpDir(t)
+
+	err := resource.Download(dir, NoFileMode, context.Background(), DownloadOptions{})
+	assert.NotNil(t, err)
+	test.AssertFileContains(t, tempFileName(u, dir), content[:3])
+
+	err = resource.Download(dir, NoFileMode, context.Background(), DownloadOptions{})
+	require.Nil(t, err)
+	test.AssertFileContains(t, filepath.Join(dir, "test.html"), content)
+	assert.NoFileExists(t, tempFileName(u, dir))
+	assert.NoFileExists(t, validatorFileName(tempFileName(u, dir)))
+}
+
+func TestGetUrlToDirWithoutRangeSupport(t *testing.T) {
+	content := `abcdef`
+	port := test.TestHttpHandler(content, t)
+	u := fmt.Sprintf("http://localhost:%d/test.html", port)
+	dir := test.TmpDir(t)
+	err := os.WriteFile(tempFileName(u, dir), []byte("abc"), 0644)
+	require.Nil(t, err)
+	err = os.WriteFile(validatorFileName(tempFileName(u, dir)), []byte(`"v1"`), 0644)
+	require.Nil(t, err)
+	hasher, _ := newHasher("sha256")
+	path, err := GetUrlToDir(u, dir, "", hasher, context.Background())
+	require.Nil(t, err)
+	test.AssertFileContains(t, path, content)
+	assert.Equal(t, test.GetSha256Integrity(content), integrityFromHasher("sha256", hasher))
+}
+
 func TestResourceDownloadWithValidFileAlreadyPresent(t *testing.T) {
 	content := `abcdef`
 	contentIntegrity := test.GetSha256Integrity(content)
@@ -63,7 +197,7 @@ func TestResourceDownloadWithValidFileAlreadyPresent(t *testing.T) {
 	outputDir := test.TmpDir(t)
 	err := os.WriteFile(filepath.Join(outputDir, testFileName), []byte(content), 0644)
 	assert.Nil(t, err)
-	err = resource.Download(outputDir, 0644, context.Background())
+	err = resource.Download(outputDir, 0644, context.Background(), DownloadOptions{})
 	assert.Nil(t, err)
 	for _, file := range []string{testFileName} {
 		test.AssertFileContains(t, fmt.Sprintf("%s/%s", outputDir, file), content)
@@ -79,7 +213,7 @@ func TestResourceDownloadWithInValidFileAlreadyPresent(t *testing.T) {
 	outputDir := test.TmpDir(t)
 	err := os.WriteFile(filepath.Join(outputDir, testFileName), []byte("invalid"), 0644)
 	assert.Nil(t, err)
-	err = resource.Download(outputDir, 0644, context.Background())
+	err = resource.Download(outputDir, 0644, context.Background(), DownloadOptions{})
 	assert.NotNil(t, err)
 	assert.Contains(t, err.Error(), "integrity mismatch")
 	assert.Contains(t, err.Error(), "existing file")
@@ -110,7 +244,7 @@ func TestUseResourceWithCache(t *testing.T) {
 	outputDir := test.TmpDir(t)
 
 	// Download resource from cache.
-	err = resource.Download(outputDir, 0644, context.Background())
+	err = resource.Download(outputDir, 0644, context.Background(), DownloadOptions{})
 	require.Nil(t, err)
 	for _, file := range []string{"test.txt"} {
 		test.AssertFileContains(t, fmt.Sprintf("%s/%s", outputDir, file), content)
@@ -154,7 +288,7 @@ func TestResourceWithCacheCorruptedCache(t *testing.T) {
 	outputDir := test.TmpDir(t)
 
 	// Download resource from cache.
-	err = resource.Download(outputDir, 0644, context.Background())
+	err = resource.Download(outputDir, 0644, context.Background(), DownloadOptions{})
 	assert.NotNil(t, err)
 	assert.Contains(t, err.Error(), "cache file at")
 	assert.Contains(t, err.Error(), "with incorrect integrity")
@@ -168,3 +302,32 @@ func TestResourceWithCacheNoToken(t *testing.T) {
 	_, err := NewResourceFromUrl([]string{sourceURL}, "sha256", []string{}, fileName, "http://localhost:8080/")
 	assert.NotNil(t, err)
 }
+
+func TestNewResourceWithIntegrity(t *testing.T) {
+	content := `abcdef`
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	server, port := test.NewRecorderHttpServer(handler, t)
+	url := fmt.Sprintf("http://localhost:%d/test.html", port)
+	integrity := test.GetSha256Integrity(content)
+
+	resource, err := NewResource(Resource{Urls: []string{url}}, AddOptions{Integrity: integrity})
+	require.Nil(t, err)
+	assert.Equal(t, integrity, resource.Integrity)
+	assert.Empty(t, *server.Requests)
+
+	_, err = NewResource(Resource{Urls: []string{url}, Extract: true}, AddOptions{Integrity: integrity, Manifest: true})
+	assert.ErrorContains(t, err, "requires downloading the resource")
+
+	resource, err = NewResource(Resource{Urls: []string{url}}, AddOptions{Integrity: integrity, VerifyNow: true})
+	require.Nil(t, err)
+	assert.Equal(t, integrity, resource.Integrity)
+	assert.Len(t, *server.Requests, 1)
+
+	_, err = NewResource(Resource{Urls: []string{url}}, AddOptions{Integrity: test.GetSha256Integrity("other"), VerifyNow: true})
+	assert.ErrorContains(t, err, "integrity mismatch")
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/carlmjohnson/requests"
+	"github.com/rs/zerolog/log"
+)
+
+// RetryPolicy controls how failed downloads are retried.
+type RetryPolicy struct {
+	// Retries is the number of retries after the first attempt.
+	Retries int
+	// Backoff is the delay before the first retry. It doubles with
+	// every retry, up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Jitter randomly varies each delay by up to this fraction of it.
+	Jitter float64
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	Retries:    0,
+	Backoff:    time.Second,
+	MaxBackoff: 30 * time.Second,
+	Jitter:     0.2,
+}
+
+// delay returns how long to wait before the given retry (starting at 0)
+// of a request that failed with err.
+func (p RetryPolicy) delay(retry int, err error) time.Duration {
+	d := p.Backoff << retry
+	if d <= 0 || d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(d))
+	}
+	// Servers asking to slow down are given at least what they ask for,
+	// within MaxBackoff.
+	if retryAfter, ok := getRetryAfter(err); ok && retryAfter > d {
+		d = min(retryAfter, p.MaxBackoff)
+	}
+	return max(d, 0)
+}
+
+// retry calls f until it succeeds, fails with an error that is not
+// retryable or all the retries are exhausted.
+func (p RetryPolicy) retry(ctx context.Context, u string, f func() error) error {
+	err := f()
+	for retry := 0; retry < p.Retries && err != nil && isRetryable(err); retry++ {
+		d := p.delay(retry, err)
+		log.Warn().Msgf("Retrying '%s' in %s (%d/%d): %v", u, d.Round(time.Millisecond), retry+1, p.Retries, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(d):
+		}
+		err = f()
+	}
+	return err
+}
+
+// isRetryable returns true if the download error is likely transient:
+// server errors, rate limiting, timeouts and connection resets.
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if se := new(requests.ResponseError); errors.As(err, &se) {
+		return se.StatusCode >= 500 || se.StatusCode == http.StatusTooManyRequests
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// getRetryAfter returns the delay requested by the Retry-After header of
+// the response that caused err, if any.
+func getRetryAfter(err error) (time.Duration, bool) {
+	se := new(requests.ResponseError)
+	if !errors.As(err, &se) {
+		return 0, false
+	}
+	header := se.Header.Get("Retry-After")
+	if header == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		return time.Until(date), true
+	}
+	return 0, false
+}
+
+// retryPolicy returns the given policy with the overrides of the resource
+// applied.
+func (l *Resource) retryPolicy(p RetryPolicy) (RetryPolicy, error) {
+	if l.Retries != 0 {
+		p.Retries = l.Retries
+	}
+	if l.RetryBackoff != "" {
+		backoff, err := time.ParseDuration(l.RetryBackoff)
+		if err != nil {
+			return p, fmt.Errorf("invalid retry backoff '%s' for '%s': %w", l.RetryBackoff, l.Urls[0], err)
+		}
+		p.Backoff = backoff
+	}
+	return p, nil
+}
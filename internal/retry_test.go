@@ -0,0 +1,123 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/carlmjohnson/requests"
+	"github.com/cisco-open/grabit/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// statusErr returns the error of a request answered with the given status.
+func statusErr(code int, header http.Header) error {
+	res := &http.Response{StatusCode: code, Header: header, Request: httptest.NewRequest(http.MethodGet, "http://localhost/", nil)}
+	return requests.CheckStatus(http.StatusOK)(res)
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		retryable bool
+	}{
+		{"502", statusErr(http.StatusBadGateway, http.Header{}), true},
+		{"429", statusErr(http.StatusTooManyRequests, http.Header{}), true},
+		{"404", statusErr(http.StatusNotFound, http.Header{}), false},
+		{"reset", fmt.Errorf("failed: %w", syscall.ECONNRESET), true},
+		{"canceled", fmt.Errorf("failed: %w", context.Canceled), false},
+		{"other", fmt.Errorf("integrity mismatch"), false},
+	}
+	for _, data := range tests {
+		t.Run(data.name, func(t *testing.T) {
+			assert.Equal(t, data.retryable, isRetryable(data.err))
+		})
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{Retries: 5, Backoff: time.Second, MaxBackoff: 5 * time.Second}
+	assert.Equal(t, time.Second, p.delay(0, nil))
+	assert.Equal(t, 4*time.Second, p.delay(2, nil))
+	assert.Equal(t, 5*time.Second, p.delay(3, nil))
+
+	err := statusErr(http.StatusTooManyRequests, http.Header{"Retry-After": []string{"3"}})
+	assert.Equal(t, 3*time.Second, p.delay(0, err))
+	err = statusErr(http.StatusTooManyRequests, http.Header{"Retry-After": []string{"3600"}})
+	assert.Equal(t, 5*time.Second, p.delay(0, err))
+
+	p.Jitter = 0.5
+	for range 10 {
+		d := p.delay(0, nil)
+		assert.True(t, d >= 500*time.Millisecond && d <= 1500*time.Millisecond)
+	}
+}
+
+func TestResourceDownloadRetries(t *testing.T) {
+	content := `abcdef`
+	failures := 2
+	calls := 0
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls <= failures {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, err := w.Write([]byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	port, server := test.HttpHandler(handler)
+	defer server.Close()
+	resource := Resource{Urls: []string{fmt.Sprintf("http://localhost:%d/test.html", port)}, Integrity: test.GetSha256Integrity(content)}
+	opts := DownloadOptions{Retry: RetryPolicy{Retries: 1, Backoff: time.Millisecond, MaxBackoff: time.Millisecond}}
+
+	// Not enough retries.
+	err := resource.Download(test.TmpDir(t), NoFileMode, context.Background(), opts)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "unexpected status: 502")
+	assert.Equal(t, 2, calls)
+
+	// Resource override.
+	calls = 0
+	resource.Retries = 2
+	resource.RetryBackoff = "1ms"
+	outputDir := test.TmpDir(t)
+	err = resource.Download(outputDir, NoFileMode, context.Background(), opts)
+	require.Nil(t, err)
+	assert.Equal(t, 3, calls)
+	test.AssertFileContains(t, filepath.Join(outputDir, "test.html"), content)
+}
+
+func TestResourceDownloadDoesNotRetryClientErrors(t *testing.T) {
+	calls := 0
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusNotFound)
+	}
+	port, server := test.HttpHandler(handler)
+	defer server.Close()
+	resource := Resource{Urls: []string{fmt.Sprintf("http://localhost:%d/test.html", port)}, Integrity: test.GetSha256Integrity("abcdef")}
+	opts := DownloadOptions{Retry: RetryPolicy{Retries: 3, Backoff: time.Millisecond, MaxBackoff: time.Millisecond}}
+	err := resource.Download(test.TmpDir(t), NoFileMode, context.Background(), opts)
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestResourceInvalidRetryBackoff(t *testing.T) {
+	resource := Resource{Urls: []string{"http://localhost:33/test.html"}, Integrity: test.GetSha256Integrity("abcdef"), RetryBackoff: "bogus"}
+	err := resource.Download(test.TmpDir(t), NoFileMode, context.Background(), DownloadOptions{})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "invalid retry backoff")
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	toml "github.com/pelletier/go-toml/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// LOCK_VERSION is the version of the lock file schema written by this
+// version of grabit. Lock files without a version predate versioning and
+// are version 0.
+const LOCK_VERSION = 1
+
+// checkVersion returns an error if the lock file at path, of the given
+// version, cannot be read by this version of grabit.
+func checkVersion(path string, version int) error {
+	if version < 0 {
+		return fmt.Errorf("invalid version %d of lock file '%s'", version, path)
+	}
+	if version > LOCK_VERSION {
+		return fmt.Errorf("lock file '%s' has version %d but this grabit only supports versions up to %d: upgrade grabit", path, version, LOCK_VERSION)
+	}
+	return nil
+}
+
+// warnUnknownKeys logs the keys of the lock file at path that are reported
+// by err as unknown to this version of grabit. It returns the other
+// errors.
+func warnUnknownKeys(path string, err error) error {
+	var missing *toml.StrictMissingError
+	if !errors.As(err, &missing) {
+		return err
+	}
+	for _, e := range missing.Errors {
+		row, _ := e.Position()
+		log.Warn().Msgf("Ignoring unknown key '%s' at line %d of lock file '%s'", strings.Join(e.Key(), "."), row, path)
+	}
+	return nil
+}
+
+// Version returns the schema version of the lock file.
+func (l *Lock) Version() int {
+	return l.conf.Version
+}
+
+// Migrate upgrades the lock file to LOCK_VERSION and returns its previous
+// version. The lock file needs to be saved afterwards.
+func (l *Lock) Migrate() int {
+	from := l.conf.Version
+	// Version 1 only introduced the version itself: there is nothing else
+	// to migrate yet.
+	l.conf.Version = max(l.conf.Version, LOCK_VERSION)
+	return from
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cisco-open/grabit/test"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLockVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		err     string
+	}{
+		{"unversioned", "", ""},
+		{"current", fmt.Sprintf("Version = %d", LOCK_VERSION), ""},
+		{"future", fmt.Sprintf("Version = %d", LOCK_VERSION+1), "upgrade grabit"},
+		{"negative", "Version = -1", "invalid version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := test.TmpFile(t, tt.version+"\n"+singleResourceLock)
+			_, err := NewLock(path, false)
+			if tt.err == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestNewLockUnknownKeys(t *testing.T) {
+	buf := new(bytes.Buffer)
+	logger := log.Logger
+	log.Logger = zerolog.New(buf)
+	t.Cleanup(func() { log.Logger = logger })
+	path := test.TmpFile(t, `Generator = 'grabit'
+
+[[Resource]]
+Urls = ['http://localhost:123456/a.html']
+Integrity = 'sha256-a'
+Mirror = 'http://mirror/a.html'
+`)
+	lock, err := NewLock(path, false)
+	require.Nil(t, err)
+	assert.Equal(t, "sha256-a", lock.conf.Resource[0].Integrity)
+	assert.Contains(t, buf.String(), "Ignoring unknown key 'Generator' at line 1")
+	assert.Contains(t, buf.String(), "Ignoring unknown key 'Resource.Mirror' at line 6")
+
+	// Unknown keys are kept as long as their resource is not rewritten.
+	lock.conf.Resource[0].Integrity = "sha256-b"
+	err = lock.Save()
+	require.Nil(t, err)
+	content, err := os.ReadFile(path)
+	require.Nil(t, err)
+	assert.Contains(t, string(content), "Mirror = 'http://mirror/a.html'")
+}
+
+func TestLockMigrate(t *testing.T) {
+	path := test.TmpFile(t, "# Assets of the build.\n\n"+singleResourceLock)
+	lock, err := NewLock(path, false)
+	require.Nil(t, err)
+	assert.Equal(t, 0, lock.Version())
+	assert.Equal(t, 0, lock.Migrate())
+	assert.Equal(t, LOCK_VERSION, lock.Version())
+	err = lock.Save()
+	require.Nil(t, err)
+	test.AssertFileContains(t, path, fmt.Sprintf("# Assets of the build.\nVersion = %d\n\n", LOCK_VERSION)+singleResourceLock)
+
+	lock, err = NewLock(path, false)
+	require.Nil(t, err)
+	assert.Equal(t, LOCK_VERSION, lock.Migrate())
+}
+
+func TestNewLockFileVersion(t *testing.T) {
+	path := filepath.Join(test.TmpDir(t), "grabit.lock")
+	lock, err := NewLock(path, true)
+	require.Nil(t, err)
+	lock.conf.Resource = []Resource{{Urls: []string{"http://localhost:123456/a.html"}, Integrity: "sha256-a"}}
+	err = lock.Save()
+	require.Nil(t, err)
+	test.AssertFileContains(t, path, fmt.Sprintf("Version = %d\n\n", LOCK_VERSION)+singleResourceLock)
+}
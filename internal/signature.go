@@ -0,0 +1,266 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"aead.dev/minisign"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/carlmjohnson/requests"
+	"github.com/rs/zerolog/log"
+)
+
+// Signature formats.
+const (
+	SignatureOpenPGP  = "openpgp"
+	SignatureMinisign = "minisign"
+	SignatureSignify  = "signify"
+)
+
+// armorPrefix starts ASCII armored OpenPGP keys and signatures.
+const armorPrefix = "-----BEGIN PGP"
+
+// signatureFormat returns the format of the signature of the resource.
+func (l *Resource) signatureFormat() string {
+	if l.SignatureFormat == "" {
+		return SignatureOpenPGP
+	}
+	return l.SignatureFormat
+}
+
+// checkSignatureSettings returns an error if the signature settings of
+// the resource are incomplete.
+func (l *Resource) checkSignatureSettings() error {
+	if l.SignatureUrl == "" {
+		if l.PublicKey != "" || l.Keyring != "" {
+			return fmt.Errorf("resource '%s' has a public key but no signature url", l.Urls[0])
+		}
+		if l.SignatureFormat != "" {
+			return fmt.Errorf("resource '%s' has a signature format but no signature url", l.Urls[0])
+		}
+		return nil
+	}
+	switch l.signatureFormat() {
+	case SignatureOpenPGP:
+		if l.PublicKey == "" && l.Keyring == "" {
+			return fmt.Errorf("resource '%s' has a signature url but neither a public key nor a keyring", l.Urls[0])
+		}
+		if l.PublicKey != "" && l.Keyring != "" {
+			return fmt.Errorf("resource '%s' has both a public key and a keyring", l.Urls[0])
+		}
+	case SignatureMinisign, SignatureSignify:
+		if l.PublicKey == "" {
+			return fmt.Errorf("resource '%s' has a %s signature url but no public key", l.Urls[0], l.SignatureFormat)
+		}
+		if l.Keyring != "" {
+			return fmt.Errorf("keyrings are only supported for %s signatures", SignatureOpenPGP)
+		}
+	default:
+		return fmt.Errorf("unknown signature format '%s' (available formats: %s, %s, %s)", l.SignatureFormat, SignatureOpenPGP, SignatureMinisign, SignatureSignify)
+	}
+	return nil
+}
+
+// verifySignature checks the file at path against the detached signature
+// of the resource, if it has one.
+func (l *Resource) verifySignature(path string, baseDir string, ctx context.Context) error {
+	if l.SignatureUrl == "" {
+		return nil
+	}
+	err := l.checkSignatureSettings()
+	if err != nil {
+		return err
+	}
+	signature, err := fetchSignature(l.SignatureUrl, ctx)
+	if err != nil {
+		return err
+	}
+	switch l.signatureFormat() {
+	case SignatureMinisign:
+		err = l.verifyMinisign(path, signature)
+	case SignatureSignify:
+		err = l.verifySignify(path, signature)
+	default:
+		err = l.verifyOpenPGP(path, signature, baseDir)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid signature of '%s' from '%s': %w", l.Urls[0], l.SignatureUrl, err)
+	}
+	return nil
+}
+
+// verifyOpenPGP checks the file at path against an OpenPGP signature,
+// armored or not.
+func (l *Resource) verifyOpenPGP(path string, signature []byte, baseDir string) error {
+	keyring, err := l.openPGPKeyring(baseDir)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var signer *openpgp.Entity
+	if bytes.HasPrefix(bytes.TrimSpace(signature), []byte(armorPrefix)) {
+		signer, err = openpgp.CheckArmoredDetachedSignature(keyring, file, bytes.NewReader(signature), nil)
+	} else {
+		signer, err = openpgp.CheckDetachedSignature(keyring, file, bytes.NewReader(signature), nil)
+	}
+	if err != nil {
+		return err
+	}
+	log.Debug().Str("URL", l.Urls[0]).Msgf("Valid signature from key %s", signer.PrimaryKey.KeyIdString())
+	return nil
+}
+
+// verifyMinisign checks the file at path against a minisign signature,
+// including its trusted comment.
+func (l *Resource) verifyMinisign(path string, signature []byte) error {
+	var publicKey minisign.PublicKey
+	err := publicKey.UnmarshalText([]byte(strings.TrimSpace(l.PublicKey)))
+	if err != nil {
+		return err
+	}
+	var sig minisign.Signature
+	err = sig.UnmarshalText(signature)
+	if err != nil {
+		return err
+	}
+	if sig.KeyID != publicKey.ID() {
+		return fmt.Errorf("signed by key %X instead of %X", sig.KeyID, publicKey.ID())
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var valid bool
+	if sig.Algorithm == minisign.HashEdDSA {
+		// Pre-hashed signatures can be checked without loading the file.
+		r := minisign.NewReader(file)
+		_, err = io.Copy(io.Discard, r)
+		if err != nil {
+			return err
+		}
+		valid = r.Verify(publicKey, signature)
+	} else {
+		message, err := io.ReadAll(file)
+		if err != nil {
+			return err
+		}
+		valid = minisign.Verify(publicKey, message, signature)
+	}
+	if !valid {
+		return fmt.Errorf("the signature or its trusted comment does not match")
+	}
+	log.Debug().Str("URL", l.Urls[0]).Msgf("Valid signature with trusted comment '%s'", sig.TrustedComment)
+	return nil
+}
+
+// signifyAlgorithm prefixes the binary signify keys and signatures.
+const signifyAlgorithm = "Ed"
+
+// verifySignify checks the file at path against a signify signature.
+func (l *Resource) verifySignify(path string, signature []byte) error {
+	keyID, publicKey, err := decodeSignify([]byte(l.PublicKey), ed25519.PublicKeySize)
+	if err != nil {
+		return fmt.Errorf("invalid public key: %w", err)
+	}
+	sigKeyID, sig, err := decodeSignify(signature, ed25519.SignatureSize)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(keyID, sigKeyID) {
+		return fmt.Errorf("signed by key %X instead of %X", sigKeyID, keyID)
+	}
+	// Signify signs the whole message.
+	message, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(publicKey, message, sig) {
+		return fmt.Errorf("the signature does not match")
+	}
+	return nil
+}
+
+// decodeSignify decodes a signify key or signature file, made of an
+// untrusted comment and of the base64 encoding of the algorithm, a key
+// number and size bytes of data. It returns the key number and the data.
+func decodeSignify(content []byte, size int) ([]byte, []byte, error) {
+	var encoded string
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "untrusted comment:") {
+			encoded = line
+			break
+		}
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid signify encoding: %w", err)
+	}
+	if len(decoded) != len(signifyAlgorithm)+8+size || string(decoded[:len(signifyAlgorithm)]) != signifyAlgorithm {
+		return nil, nil, fmt.Errorf("invalid signify data")
+	}
+	return decoded[2:10], decoded[10:], nil
+}
+
+// openPGPKeyring returns the keys trusted to sign the resource, read from
+// its armored PublicKey or from its Keyring file.
+func (l *Resource) openPGPKeyring(baseDir string) (openpgp.EntityList, error) {
+	keys := []byte(l.PublicKey)
+	if l.Keyring != "" {
+		var err error
+		keys, err = os.ReadFile(resolvePath(l.Keyring, baseDir))
+		if err != nil {
+			return nil, fmt.Errorf("cannot read keyring of '%s': %w", l.Urls[0], err)
+		}
+	}
+	var keyring openpgp.EntityList
+	var err error
+	if strings.HasPrefix(strings.TrimSpace(string(keys)), armorPrefix) {
+		keyring, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(keys))
+	} else {
+		keyring, err = openpgp.ReadKeyRing(bytes.NewReader(keys))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key of '%s': %w", l.Urls[0], err)
+	}
+	return keyring, nil
+}
+
+// fetchSignature downloads the signature at u.
+func fetchSignature(u string, ctx context.Context) ([]byte, error) {
+	var buf bytes.Buffer
+	err := requests.
+		URL(u).
+		Transport(noCompressionTransport).
+		ToBytesBuffer(&buf).
+		Fetch(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get signature '%s': %w", u, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// resolvePath returns the path of a file referenced by a resource setting.
+// Relative paths are relative to baseDir, usually the directory of the lock
+// file, or to the current directory if it is empty.
+func resolvePath(p string, baseDir string) string {
+	if baseDir == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(baseDir, p)
+}
@@ -0,0 +1,200 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cisco-open/grabit/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signedContentHandler serves content at /test.html and the given
+// signature at /test.html.asc and /test.html.sig.
+func signedContentHandler(t *testing.T, content string, signature string) int {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		body := content
+		if r.URL.Path == "/test.html.asc" || r.URL.Path == "/test.html.sig" {
+			body = signature
+		}
+		_, err := w.Write([]byte(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	port, server := test.HttpHandler(handler)
+	t.Cleanup(server.Close)
+	return port
+}
+
+func TestResourceDownloadOpenPGPSignature(t *testing.T) {
+	content := `abcdef`
+	signer, publicKey := test.NewOpenPGPKey(t)
+	_, otherPublicKey := test.NewOpenPGPKey(t)
+	port := signedContentHandler(t, content, test.OpenPGPSign(t, signer, content))
+	keyring := test.TmpFile(t, publicKey)
+
+	tests := []struct {
+		name          string
+		publicKey     string
+		keyring       string
+		errorContains string
+	}{
+		{"public key", publicKey, "", ""},
+		{"keyring", "", keyring, ""},
+		{"other key", otherPublicKey, "", "invalid signature"},
+		{"no key", "", "", "neither a public key nor a keyring"},
+	}
+	for _, data := range tests {
+		t.Run(data.name, func(t *testing.T) {
+			resource := Resource{
+				Urls:         []string{fmt.Sprintf("http://localhost:%d/test.html", port)},
+				Integrity:    test.GetSha256Integrity(content),
+				SignatureUrl: fmt.Sprintf("http://localhost:%d/test.html.asc", port),
+				PublicKey:    data.publicKey,
+				Keyring:      data.keyring,
+			}
+			dir := test.TmpDir(t)
+			err := resource.Download(dir, 0644, context.Background(), DownloadOptions{})
+			if data.errorContains == "" {
+				assert.Nil(t, err)
+				test.AssertFileContains(t, filepath.Join(dir, "test.html"), content)
+			} else {
+				assert.ErrorContains(t, err, data.errorContains)
+				// The file is not moved into place.
+				files, err := os.ReadDir(dir)
+				require.Nil(t, err)
+				assert.Empty(t, files)
+			}
+		})
+	}
+}
+
+func TestNewResourceOpenPGPSignature(t *testing.T) {
+	content := `abcdef`
+	signer, publicKey := test.NewOpenPGPKey(t)
+	port := signedContentHandler(t, content, test.OpenPGPSign(t, signer, "tampered"))
+	template := Resource{
+		Urls:         []string{fmt.Sprintf("http://localhost:%d/test.html", port)},
+		SignatureUrl: fmt.Sprintf("http://localhost:%d/test.html.asc", port),
+		PublicKey:    publicKey,
+	}
+	_, err := NewResource(template, AddOptions{Algo: "sha256"})
+	assert.ErrorContains(t, err, "invalid signature")
+
+	_, err = NewResource(template, AddOptions{Integrity: test.GetSha256Integrity(content)})
+	assert.ErrorContains(t, err, "verifying the signature requires downloading the resource")
+
+	template.SignatureUrl = ""
+	_, err = NewResource(template, AddOptions{Algo: "sha256"})
+	assert.ErrorContains(t, err, "has a public key but no signature url")
+}
+
+func TestResourceDownloadMinisignSignature(t *testing.T) {
+	content := `abcdef`
+	signer, publicKey := test.NewMinisignKey(t)
+	_, otherPublicKey := test.NewMinisignKey(t)
+	signature := test.MinisignSign(t, signer, content, "timestamp:1700000000\tfile:test.html")
+	tamperedComment := strings.Replace(signature, "file:test.html", "file:other.html", 1)
+
+	tests := []struct {
+		name          string
+		signature     string
+		publicKey     string
+		keyring       string
+		errorContains string
+	}{
+		{"valid", signature, publicKey, "", ""},
+		{"prehashed", test.MinisignSignPrehashed(t, signer, content, "file:test.html"), publicKey, "", ""},
+		{"prehashed tampered content", test.MinisignSignPrehashed(t, signer, "tampered", ""), publicKey, "", "does not match"},
+		{"key only", signature, strings.Split(publicKey, "\n")[1], "", ""},
+		{"other key", signature, otherPublicKey, "", "signed by key"},
+		{"tampered content", test.MinisignSign(t, signer, "tampered", ""), publicKey, "", "does not match"},
+		{"tampered trusted comment", tamperedComment, publicKey, "", "trusted comment does not match"},
+		{"keyring", signature, "", test.TmpFile(t, publicKey), "no public key"},
+	}
+	for _, data := range tests {
+		t.Run(data.name, func(t *testing.T) {
+			port := signedContentHandler(t, content, data.signature)
+			resource := Resource{
+				Urls:            []string{fmt.Sprintf("http://localhost:%d/test.html", port)},
+				Integrity:       test.GetSha256Integrity(content),
+				SignatureUrl:    fmt.Sprintf("http://localhost:%d/test.html.asc", port),
+				SignatureFormat: SignatureMinisign,
+				PublicKey:       data.publicKey,
+				Keyring:         data.keyring,
+			}
+			dir := test.TmpDir(t)
+			err := resource.Download(dir, 0644, context.Background(), DownloadOptions{})
+			if data.errorContains == "" {
+				assert.Nil(t, err)
+				test.AssertFileContains(t, filepath.Join(dir, "test.html"), content)
+			} else {
+				assert.ErrorContains(t, err, data.errorContains)
+				files, err := os.ReadDir(dir)
+				require.Nil(t, err)
+				assert.Empty(t, files)
+			}
+		})
+	}
+}
+
+func TestResourceDownloadSignifySignature(t *testing.T) {
+	content := `abcdef`
+	signer, publicKey := test.NewSignifyKey(t)
+	_, otherPublicKey := test.NewSignifyKey(t)
+
+	tests := []struct {
+		name          string
+		signature     string
+		publicKey     string
+		errorContains string
+	}{
+		{"valid", test.SignifySign(t, signer, content), publicKey, ""},
+		{"other key", test.SignifySign(t, signer, content), otherPublicKey, "signed by key"},
+		{"tampered content", test.SignifySign(t, signer, "tampered"), publicKey, "signature does not match"},
+		{"invalid signature", "untrusted comment: test\nabcdef\n", publicKey, "invalid signify"},
+		{"invalid key", test.SignifySign(t, signer, content), "untrusted comment: test\n", "invalid public key"},
+	}
+	for _, data := range tests {
+		t.Run(data.name, func(t *testing.T) {
+			port := signedContentHandler(t, content, data.signature)
+			resource := Resource{
+				Urls:            []string{fmt.Sprintf("http://localhost:%d/test.html", port)},
+				Integrity:       test.GetSha256Integrity(content),
+				SignatureUrl:    fmt.Sprintf("http://localhost:%d/test.html.sig", port),
+				SignatureFormat: SignatureSignify,
+				PublicKey:       data.publicKey,
+			}
+			dir := test.TmpDir(t)
+			err := resource.Download(dir, 0644, context.Background(), DownloadOptions{})
+			if data.errorContains == "" {
+				assert.Nil(t, err)
+				test.AssertFileContains(t, filepath.Join(dir, "test.html"), content)
+			} else {
+				assert.ErrorContains(t, err, data.errorContains)
+			}
+		})
+	}
+}
+
+func TestCheckSignatureSettings(t *testing.T) {
+	resource := Resource{
+		Urls:            []string{"http://localhost:123456/test.html"},
+		SignatureUrl:    "http://localhost:123456/test.html.sig",
+		SignatureFormat: "x509",
+		PublicKey:       "key",
+	}
+	assert.ErrorContains(t, resource.checkSignatureSettings(), "unknown signature format 'x509'")
+	resource.SignatureUrl = ""
+	resource.PublicKey = ""
+	assert.ErrorContains(t, resource.checkSignatureSettings(), "has a signature format but no signature url")
+}
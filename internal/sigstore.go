@@ -0,0 +1,85 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sigstore/sigstore-go/pkg/bundle"
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/sigstore/sigstore-go/pkg/verify"
+)
+
+// checkSigstoreSettings returns an error if the Sigstore settings of the
+// resource are incomplete.
+func (l *Resource) checkSigstoreSettings() error {
+	if l.SigstoreBundleUrl == "" {
+		if l.SigstoreTrustedRoot != "" || l.SigstoreIdentity != "" || l.SigstoreIssuer != "" {
+			return fmt.Errorf("resource '%s' has Sigstore settings but no bundle url", l.Urls[0])
+		}
+		return nil
+	}
+	if l.SigstoreTrustedRoot == "" {
+		return fmt.Errorf("resource '%s' has a Sigstore bundle url but no trusted root", l.Urls[0])
+	}
+	if l.SigstoreIdentity == "" || l.SigstoreIssuer == "" {
+		return fmt.Errorf("resource '%s' has a Sigstore bundle url but no expected identity and issuer", l.Urls[0])
+	}
+	return nil
+}
+
+// verifySigstoreBundle checks the file at path against the Sigstore bundle
+// of the resource, if it has one. The bundle is verified offline, against
+// the trusted root file of the resource: the certificate must chain up to
+// one of its certificate authorities and have been issued to the expected
+// identity by the expected issuer, and the signature must be included in
+// one of its transparency logs.
+func (l *Resource) verifySigstoreBundle(path string, baseDir string, ctx context.Context) error {
+	if l.SigstoreBundleUrl == "" {
+		return nil
+	}
+	err := l.checkSigstoreSettings()
+	if err != nil {
+		return err
+	}
+	trustedRoot, err := root.NewTrustedRootFromPath(resolvePath(l.SigstoreTrustedRoot, baseDir))
+	if err != nil {
+		return fmt.Errorf("invalid Sigstore trusted root '%s': %w", l.SigstoreTrustedRoot, err)
+	}
+	content, err := fetchSignature(l.SigstoreBundleUrl, ctx)
+	if err != nil {
+		return err
+	}
+	var b bundle.Bundle
+	err = b.UnmarshalJSON(content)
+	if err != nil {
+		return fmt.Errorf("invalid Sigstore bundle '%s': %w", l.SigstoreBundleUrl, err)
+	}
+	opts := []verify.VerifierOption{verify.WithTransparencyLog(1), verify.WithObserverTimestamps(1)}
+	if len(trustedRoot.CTLogs()) > 0 {
+		opts = append(opts, verify.WithSignedCertificateTimestamps(1))
+	}
+	verifier, err := verify.NewVerifier(trustedRoot, opts...)
+	if err != nil {
+		return fmt.Errorf("invalid Sigstore trusted root '%s': %w", l.SigstoreTrustedRoot, err)
+	}
+	identity, err := verify.NewShortCertificateIdentity(l.SigstoreIssuer, "", l.SigstoreIdentity, "")
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = verifier.Verify(&b, verify.NewPolicy(verify.WithArtifact(file), verify.WithCertificateIdentity(identity)))
+	if err != nil {
+		return fmt.Errorf("invalid Sigstore bundle of '%s' from '%s': %w", l.Urls[0], l.SigstoreBundleUrl, err)
+	}
+	log.Debug().Str("URL", l.Urls[0]).Msgf("Valid Sigstore bundle signed by '%s'", l.SigstoreIdentity)
+	return nil
+}
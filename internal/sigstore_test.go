@@ -0,0 +1,97 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cisco-open/grabit/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testSigstoreIdentity = "https://github.com/example/tool/.github/workflows/release.yml@refs/tags/v1.0"
+	testSigstoreIssuer   = "https://token.actions.githubusercontent.com"
+)
+
+func TestResourceDownloadSigstoreBundle(t *testing.T) {
+	content := `abcdef`
+	sigstore, trustedRoot := test.NewSigstore(t)
+	_, otherTrustedRoot := test.NewSigstore(t)
+	bundle := test.SigstoreSign(t, sigstore, testSigstoreIdentity, testSigstoreIssuer, content)
+
+	tests := []struct {
+		name          string
+		bundle        string
+		trustedRoot   string
+		identity      string
+		issuer        string
+		errorContains string
+	}{
+		{"valid", bundle, trustedRoot, testSigstoreIdentity, testSigstoreIssuer, ""},
+		{"other identity", bundle, trustedRoot, "https://github.com/example/other/.github/workflows/release.yml@refs/tags/v1.0", testSigstoreIssuer, "invalid Sigstore bundle"},
+		{"other issuer", bundle, trustedRoot, testSigstoreIdentity, "https://accounts.google.com", "invalid Sigstore bundle"},
+		{"other trusted root", bundle, otherTrustedRoot, testSigstoreIdentity, testSigstoreIssuer, "invalid Sigstore bundle"},
+		{"tampered content", test.SigstoreSign(t, sigstore, testSigstoreIdentity, testSigstoreIssuer, "tampered"), trustedRoot, testSigstoreIdentity, testSigstoreIssuer, "invalid Sigstore bundle"},
+		{"not a bundle", "{}", trustedRoot, testSigstoreIdentity, testSigstoreIssuer, "invalid Sigstore bundle"},
+		{"missing trusted root", bundle, filepath.Join(test.TmpDir(t), "trusted_root.json"), testSigstoreIdentity, testSigstoreIssuer, "invalid Sigstore trusted root"},
+		{"no identity", bundle, trustedRoot, "", testSigstoreIssuer, "no expected identity and issuer"},
+	}
+	for _, data := range tests {
+		t.Run(data.name, func(t *testing.T) {
+			port := signedContentHandler(t, content, data.bundle)
+			resource := Resource{
+				Urls:                []string{fmt.Sprintf("http://localhost:%d/test.html", port)},
+				Integrity:           test.GetSha256Integrity(content),
+				SigstoreBundleUrl:   fmt.Sprintf("http://localhost:%d/test.html.sig", port),
+				SigstoreTrustedRoot: data.trustedRoot,
+				SigstoreIdentity:    data.identity,
+				SigstoreIssuer:      data.issuer,
+			}
+			dir := test.TmpDir(t)
+			err := resource.Download(dir, 0644, context.Background(), DownloadOptions{})
+			if data.errorContains == "" {
+				assert.Nil(t, err)
+				test.AssertFileContains(t, filepath.Join(dir, "test.html"), content)
+			} else {
+				assert.ErrorContains(t, err, data.errorContains)
+				files, err := os.ReadDir(dir)
+				require.Nil(t, err)
+				assert.Empty(t, files)
+			}
+		})
+	}
+}
+
+func TestNewResourceSigstoreBundle(t *testing.T) {
+	content := `abcdef`
+	sigstore, trustedRoot := test.NewSigstore(t)
+	port := signedContentHandler(t, content, test.SigstoreSign(t, sigstore, testSigstoreIdentity, testSigstoreIssuer, content))
+	template := Resource{
+		Urls:                []string{fmt.Sprintf("http://localhost:%d/test.html", port)},
+		SigstoreBundleUrl:   fmt.Sprintf("http://localhost:%d/test.html.sig", port),
+		SigstoreTrustedRoot: trustedRoot,
+		SigstoreIdentity:    testSigstoreIdentity,
+		SigstoreIssuer:      testSigstoreIssuer,
+	}
+	resource, err := NewResource(template, AddOptions{Algo: "sha256"})
+	require.Nil(t, err)
+	assert.Equal(t, test.GetSha256Integrity(content), resource.Integrity)
+
+	template.SigstoreIssuer = "https://accounts.google.com"
+	_, err = NewResource(template, AddOptions{Algo: "sha256"})
+	assert.ErrorContains(t, err, "invalid Sigstore bundle")
+
+	_, err = NewResource(template, AddOptions{Integrity: test.GetSha256Integrity(content)})
+	assert.ErrorContains(t, err, "verifying the signature requires downloading the resource")
+
+	template.SigstoreBundleUrl = ""
+	_, err = NewResource(template, AddOptions{Algo: "sha256"})
+	assert.ErrorContains(t, err, "has Sigstore settings but no bundle url")
+}
@@ -4,46 +4,45 @@
 package internal
 
 import (
-	"bufio"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
 	"os"
 	"strings"
 )
 
-func getIntegrityFromFile(path string, algo string) (string, error) {
+// newHasher returns a new hash.Hash for the given algorithm.
+func newHasher(algo string) (hash.Hash, error) {
 	hash, err := NewHash(algo)
+	if err != nil {
+		return nil, err
+	}
+	return hash.hash(), nil
+}
+
+// integrityFromHasher returns the SRI string of the data written so far
+// to the given hasher.
+func integrityFromHasher(algo string, hasher hash.Hash) string {
+	return integrityFromDigest(algo, hasher.Sum(nil))
+}
+
+func getIntegrityFromFile(path string, algo string) (string, error) {
+	hasher, err := newHasher(algo)
 	if err != nil {
 		return "", err
 	}
-	hasher := hash.hash()
 	f, err := os.Open(path)
 	if err != nil {
-		return "", fmt.Errorf("cannot open file '%s'", path)
+		return "", fmt.Errorf("cannot open file '%s': %w", path, err)
 	}
 	defer f.Close()
-	reader := bufio.NewReader(f)
-	// each block size is 10MB
-	const chunkSize = 10 * 1024 * 1024
-	buf := make([]byte, chunkSize)
-	for {
-		n, err := reader.Read(buf)
-		buf = buf[:n]
-
-		if n == 0 {
-			if err != nil {
-				if err == io.EOF {
-					break
-				}
-				return "", err
-			}
-			break
-		}
-		hasher.Write(buf)
+	_, err = io.Copy(hasher, f)
+	if err != nil {
+		return "", err
 	}
-	h := hasher.Sum(nil)
-	return fmt.Sprintf("%s-%s", algo, base64.StdEncoding.EncodeToString(h)), nil
+	return integrityFromHasher(algo, hasher), nil
 }
 
 func checkIntegrityFromFile(path string, algo string, integrity string, u string) error {
@@ -51,6 +50,11 @@ func checkIntegrityFromFile(path string, algo string, integrity string, u string
 	if err != nil {
 		return fmt.Errorf("failed to compute resource integrity: %s", err)
 	}
+	return checkIntegrity(computedIntegrity, integrity, u)
+}
+
+// checkIntegrity compares a computed SRI string with the expected one.
+func checkIntegrity(computedIntegrity string, integrity string, u string) error {
 	if computedIntegrity != integrity {
 		return fmt.Errorf("integrity mismatch for '%s': got '%s' expected '%s'", u, computedIntegrity, integrity)
 	}
@@ -68,3 +72,41 @@ func getAlgoFromIntegrity(integrity string) (string, error) {
 	}
 	return hash.algo, nil
 }
+
+// integrityFromDigest returns the SRI string of the given digest.
+func integrityFromDigest(algo string, digest []byte) string {
+	return fmt.Sprintf("%s-%s", algo, base64.StdEncoding.EncodeToString(digest))
+}
+
+// ParseIntegrity parses a known integrity, given either as an SRI string
+// ('sha256-<base64 digest>') or as an algorithm and a hex digest
+// ('sha256:<hex digest>'), and returns it as an SRI string.
+func ParseIntegrity(integrity string) (string, error) {
+	var algo string
+	var digest []byte
+	if a, d, found := strings.Cut(integrity, ":"); found {
+		algo = strings.ToLower(a)
+		decoded, err := hex.DecodeString(d)
+		if err != nil {
+			return "", fmt.Errorf("invalid hex digest in '%s': %w", integrity, err)
+		}
+		digest = decoded
+	} else if a, d, found := strings.Cut(integrity, "-"); found {
+		algo = a
+		decoded, err := base64.StdEncoding.DecodeString(d)
+		if err != nil {
+			return "", fmt.Errorf("invalid SRI '%s': %w", integrity, err)
+		}
+		digest = decoded
+	} else {
+		return "", fmt.Errorf("invalid integrity '%s': expected '<algo>-<base64 digest>' or '<algo>:<hex digest>'", integrity)
+	}
+	hasher, err := newHasher(algo)
+	if err != nil {
+		return "", err
+	}
+	if len(digest) != hasher.Size() {
+		return "", fmt.Errorf("invalid %s digest in '%s': expected %d bytes, got %d", algo, integrity, hasher.Size(), len(digest))
+	}
+	return integrityFromDigest(algo, digest), nil
+}
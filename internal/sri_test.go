@@ -64,3 +64,41 @@ func TestCheckIntegrityFromFileInvalid(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.Contains(t, err.Error(), "integrity mismatch")
 }
+
+func TestIntegrityFromHasher(t *testing.T) {
+	hasher, err := newHasher("sha256")
+	assert.Nil(t, err)
+	hasher.Write([]byte("abc"))
+	hasher.Write([]byte("def"))
+	assert.Equal(t, "sha256-vvV+x/U6bUC+tkCngKY5yDvCmsipgW8fxsXG3Nk8RyE=", integrityFromHasher("sha256", hasher))
+
+	_, err = newHasher("bogus")
+	assert.NotNil(t, err)
+}
+
+func TestParseIntegrity(t *testing.T) {
+	sri := test.GetSha256Integrity("abcdef")
+	tests := []struct {
+		integrity     string
+		valid         bool
+		errorContains string
+	}{
+		{integrity: sri, valid: true},
+		{integrity: "sha256:bef57ec7f53a6d40beb640a780a639c83bc29ac8a9816f1fc6c5c6dcd93c4721", valid: true},
+		{integrity: "SHA256:BEF57EC7F53A6D40BEB640A780A639C83BC29AC8A9816F1FC6C5C6DCD93C4721", valid: true},
+		{integrity: "sha256:bef57e", errorContains: "expected 32 bytes"},
+		{integrity: "sha256:xyz", errorContains: "invalid hex digest"},
+		{integrity: "sha256-!!!", errorContains: "invalid SRI"},
+		{integrity: "md5:d41d8cd98f00b204e9800998ecf8427e", errorContains: "unknown hash algorithm"},
+		{integrity: "bef57ec7f53a6d40", errorContains: "invalid integrity"},
+	}
+	for _, data := range tests {
+		parsed, err := ParseIntegrity(data.integrity)
+		if data.valid {
+			assert.Nil(t, err)
+			assert.Equal(t, sri, parsed)
+		} else {
+			assert.ErrorContains(t, err, data.errorContains)
+		}
+	}
+}
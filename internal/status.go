@@ -22,6 +22,7 @@ type StatusLine struct {
 	numResourcesDownloaded int
 	spinI                  int
 	isRunning              atomic.Bool
+	done                   chan struct{}
 	startTime              time.Time
 	sizingErr              error
 	ctx                    context.Context
@@ -36,6 +37,7 @@ const timeoutMs = 1000
 func NewStatusLine(ctx context.Context, resources *[]Resource) *StatusLine {
 	st := StatusLine{}
 	st.resources = resources
+	st.resourceSizes = make([]int64, len(*resources))
 	st.ctx = ctx
 	return &st
 }
@@ -46,10 +48,11 @@ func (st *StatusLine) Increment(i int) {
 	st.mtx.Lock()
 	st.numBytesDownloaded += st.resourceSizes[i]
 	st.numResourcesDownloaded++
+	complete := st.numResourcesDownloaded == len(*st.resources)
 	st.mtx.Unlock()
 
 	fmt.Print(st.GetStatusString() + " ")
-	if st.numResourcesDownloaded == len(*st.resources) {
+	if complete {
 		fmt.Println()
 		st.Stop()
 		return
@@ -61,15 +64,23 @@ func (st *StatusLine) Increment(i int) {
 func (st *StatusLine) Start(doTick bool) {
 	st.isRunning.Store(true)
 	st.startTime = time.Now()
+	st.done = make(chan struct{})
 	go func() {
+		defer close(st.done)
 		fmt.Print(st.GetStatusString())
 		for {
 			select {
 			case <-st.ctx.Done():
-				st.Stop()
-				fmt.Println()
+				// Terminate the line if it was not already done.
+				if st.isRunning.Load() {
+					st.Stop()
+					fmt.Println()
+				}
 				return
 			case <-time.After(tickMs * time.Millisecond):
+				if !st.isRunning.Load() {
+					return
+				}
 				if !doTick {
 					continue
 				}
@@ -89,6 +100,14 @@ func (st *StatusLine) Stop() {
 	st.isRunning.Store(false)
 }
 
+// Wait waits for the goroutine started by Start to return, which happens
+// once the SL is stopped or its context is done.
+func (st *StatusLine) Wait() {
+	if st.done != nil {
+		<-st.done
+	}
+}
+
 // initResourceSizes fetches the size, in bytes, of each resource.
 func (st *StatusLine) InitResourcesSizes() error {
 	log.Debug().Msg("Fetching resource sizes")
@@ -0,0 +1,132 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// VerifyStatus is the outcome of checking a resource against the files
+// present in a download directory.
+type VerifyStatus string
+
+const (
+	VerifyOK        VerifyStatus = "ok"
+	VerifyMissing   VerifyStatus = "missing"
+	VerifyMismatch  VerifyStatus = "mismatch"
+	VerifyExtraName VerifyStatus = "extra-name"
+	// VerifyModified is the status of resources whose extracted files
+	// differ from their manifest.
+	VerifyModified VerifyStatus = "modified"
+	VerifyError    VerifyStatus = "error"
+)
+
+// VerifyResult describes the state of a single resource in a download
+// directory.
+type VerifyResult struct {
+	Resource Resource
+	Status   VerifyStatus
+	// Paths lists the files found for the resource.
+	Paths []string
+	// Diff lists the differences between the extracted files and the
+	// manifest of the resource, if it has one.
+	Diff *ManifestDiff
+	Err  error
+}
+
+// Name returns the file name the resource is expected to be stored as.
+func (v *VerifyResult) Name() string {
+	if len(v.Paths) > 0 {
+		return filepath.Base(v.Paths[0])
+	}
+	return v.Resource.localName(v.Resource.Urls[0])
+}
+
+// Verify checks the files already present in dir against the resource
+// integrity. It never accesses the network.
+func (l *Resource) Verify(dir string) VerifyResult {
+	res := VerifyResult{Resource: *l, Status: VerifyOK, Paths: []string{}}
+	algo, err := getAlgoFromIntegrity(l.Integrity)
+	if err != nil {
+		res.Status = VerifyError
+		res.Err = err
+		return res
+	}
+	for _, name := range l.localNames() {
+		resPath := filepath.Join(dir, name)
+		_, err := os.Stat(resPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			res.Status = VerifyError
+			res.Err = fmt.Errorf("error checking file presence '%s': '%v'", resPath, err)
+			return res
+		}
+		res.Paths = append(res.Paths, resPath)
+	}
+	if len(res.Paths) == 0 {
+		res.Status = VerifyMissing
+		res.Err = fmt.Errorf("resource '%s' is missing from '%s'", l.Urls[0], dir)
+		return res
+	}
+	mismatches := []error{}
+	for _, p := range res.Paths {
+		err = checkIntegrityFromFile(p, algo, l.Integrity, p)
+		if err != nil {
+			mismatches = append(mismatches, err)
+		}
+	}
+	if len(mismatches) > 0 {
+		res.Status = VerifyMismatch
+		res.Err = errors.Join(mismatches...)
+		return res
+	}
+	if len(l.Manifest) > 0 {
+		diff, err := l.VerifyExtracted(dir)
+		if err != nil {
+			res.Status = VerifyError
+			res.Err = fmt.Errorf("cannot verify extracted files of '%s': %w", l.Urls[0], err)
+			return res
+		}
+		res.Diff = &diff
+		if !diff.Empty() {
+			res.Status = VerifyModified
+			res.Err = fmt.Errorf("extracted files of '%s' differ from its manifest: %d added, %d removed, %d modified", l.Urls[0], len(diff.Added), len(diff.Removed), len(diff.Modified))
+			return res
+		}
+	}
+	if len(res.Paths) > 1 {
+		res.Status = VerifyExtraName
+		res.Err = fmt.Errorf("resource '%s' is present under several names: %s", l.Urls[0], strings.Join(res.Paths, ", "))
+	}
+	return res
+}
+
+// Verify checks the files present in dir against the resources of this
+// lock file that match the given tags. An error is returned if any of
+// them is missing, mismatching or present under several names.
+func (l *Lock) Verify(dir string, tags []string, notags []string) ([]VerifyResult, error) {
+	if stat, err := os.Stat(dir); err != nil || !stat.IsDir() {
+		return nil, fmt.Errorf("'%s' is not a directory", dir)
+	}
+	filteredResources := l.Filter(NewTagFilter(tags, notags))
+	if len(filteredResources) == 0 {
+		return nil, fmt.Errorf("nothing to verify")
+	}
+	results := []VerifyResult{}
+	errs := []error{}
+	for _, r := range filteredResources {
+		res := r.Verify(dir)
+		if res.Err != nil {
+			errs = append(errs, res.Err)
+		}
+		results = append(results, res)
+	}
+	return results, errors.Join(errs...)
+}
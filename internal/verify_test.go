@@ -0,0 +1,97 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cisco-open/grabit/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResourceVerify(t *testing.T) {
+	content := `abcdef`
+	contentIntegrity := test.GetSha256Integrity(content)
+	tests := []struct {
+		name   string
+		urls   []string
+		files  map[string]string
+		status VerifyStatus
+	}{
+		{
+			name:   "ok",
+			urls:   []string{"http://localhost:33/test.html"},
+			files:  map[string]string{"test.html": content},
+			status: VerifyOK,
+		},
+		{
+			name:   "missing",
+			urls:   []string{"http://localhost:33/test.html"},
+			files:  map[string]string{"other.html": content},
+			status: VerifyMissing,
+		},
+		{
+			name:   "mismatch",
+			urls:   []string{"http://localhost:33/test.html"},
+			files:  map[string]string{"test.html": "invalid"},
+			status: VerifyMismatch,
+		},
+		{
+			name:   "extra name",
+			urls:   []string{"http://localhost:33/test.html", "http://localhost:34/test2.html"},
+			files:  map[string]string{"test.html": content, "test2.html": content},
+			status: VerifyExtraName,
+		},
+	}
+	for _, data := range tests {
+		t.Run(data.name, func(t *testing.T) {
+			dir := test.TmpDir(t)
+			for name, c := range data.files {
+				err := os.WriteFile(filepath.Join(dir, name), []byte(c), 0644)
+				assert.Nil(t, err)
+			}
+			resource := Resource{Urls: data.urls, Integrity: contentIntegrity}
+			res := resource.Verify(dir)
+			assert.Equal(t, data.status, res.Status)
+			assert.Equal(t, data.status == VerifyOK, res.Err == nil)
+		})
+	}
+}
+
+func TestLockVerify(t *testing.T) {
+	content := `abcdef`
+	contentIntegrity := test.GetSha256Integrity(content)
+	path := test.TmpFile(t, `
+	[[Resource]]
+	Urls = ['http://localhost:33/test.html']
+	Integrity = '`+contentIntegrity+`'
+	Tags = ['tag']
+
+	[[Resource]]
+	Urls = ['http://localhost:33/test2.html']
+	Integrity = '`+contentIntegrity+`'
+`)
+	lock, err := NewLock(path, false)
+	assert.Nil(t, err)
+	dir := test.TmpDir(t)
+	err = os.WriteFile(filepath.Join(dir, "test.html"), []byte(content), 0644)
+	assert.Nil(t, err)
+
+	results, err := lock.Verify(dir, []string{"tag"}, []string{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(results))
+	assert.Equal(t, VerifyOK, results[0].Status)
+
+	results, err = lock.Verify(dir, []string{}, []string{})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "is missing")
+	assert.Equal(t, 2, len(results))
+	assert.Equal(t, VerifyMissing, results[1].Status)
+
+	_, err = lock.Verify(dir, []string{"unknown"}, []string{})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "nothing to verify")
+}
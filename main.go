@@ -5,7 +5,6 @@ package main
 
 import (
 	"os"
-	"os/signal"
 
 	"github.com/cisco-open/grabit/cmd"
 	"github.com/rs/zerolog"
@@ -16,16 +15,8 @@ func main() {
 	// Log to stdout.
 	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
 
-	// Exit immediately upon reception of an interrupt signal.
-	stopChan := make(chan os.Signal, 1)
-	signal.Notify(stopChan, os.Interrupt)
-	go listenForInterrupt(stopChan)
-
+	// Interrupt signals exit immediately, except while downloading, where
+	// they cancel the downloads so that their temporary files are removed.
 	rootCmd := cmd.NewRootCmd()
 	cmd.Execute(rootCmd)
 }
-
-func listenForInterrupt(stopScan chan os.Signal) {
-	<-stopScan
-	log.Fatal().Msg("Interrupt signal received. Exiting.")
-}
@@ -0,0 +1,134 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// ArchiveEntry describes a file of a test archive. Entries with a Link
+// are symbolic links, entries whose name ends with "/" are directories.
+type ArchiveEntry struct {
+	Name    string
+	Content string
+	Mode    int64
+	Link    string
+}
+
+// Archive returns the content of an archive of the given format (tar,
+// tar.gz, tar.xz, tar.zst or zip) holding the given entries.
+func Archive(t *testing.T, format string, entries []ArchiveEntry) string {
+	buf := &bytes.Buffer{}
+	if format == "zip" {
+		writeZip(t, buf, entries)
+		return buf.String()
+	}
+	var w io.WriteCloser
+	var err error
+	switch format {
+	case "tar":
+		w = nopCloser{buf}
+	case "tar.gz":
+		w = gzip.NewWriter(buf)
+	case "tar.xz":
+		w, err = xz.NewWriter(buf)
+	case "tar.zst":
+		w, err = zstd.NewWriter(buf)
+	default:
+		t.Fatalf("unknown archive format '%s'", format)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw := tar.NewWriter(w)
+	for _, e := range entries {
+		header := &tar.Header{Name: e.Name, Mode: entryMode(e), Size: int64(len(e.Content)), Typeflag: tar.TypeReg}
+		if e.Link != "" {
+			header.Typeflag = tar.TypeSymlink
+			header.Linkname = e.Link
+			header.Size = 0
+		} else if strings.HasSuffix(e.Name, "/") {
+			header.Typeflag = tar.TypeDir
+			header.Size = 0
+		}
+		err := tw.WriteHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = tw.Write([]byte(e.Content))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = tw.Close()
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+// ArchiveFile writes an archive of the given format holding the given
+// entries to a temporary file and returns its path.
+func ArchiveFile(t *testing.T, format string, entries []ArchiveEntry) string {
+	return TmpFile(t, Archive(t, format, entries))
+}
+
+func writeZip(t *testing.T, w io.Writer, entries []ArchiveEntry) {
+	zw := zip.NewWriter(w)
+	for _, e := range entries {
+		header := &zip.FileHeader{Name: e.Name, Method: zip.Deflate}
+		mode := os.FileMode(entryMode(e))
+		if e.Link != "" {
+			mode |= os.ModeSymlink
+		} else if strings.HasSuffix(e.Name, "/") {
+			mode |= os.ModeDir
+		}
+		header.SetMode(mode)
+		f, err := zw.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content := e.Content
+		if e.Link != "" {
+			content = e.Link
+		}
+		_, err = f.Write([]byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := zw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func entryMode(e ArchiveEntry) int64 {
+	if e.Mode != 0 {
+		return e.Mode
+	}
+	if e.Link != "" || strings.HasSuffix(e.Name, "/") {
+		return 0755
+	}
+	return 0644
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
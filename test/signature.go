@@ -0,0 +1,152 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package test
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+
+	"aead.dev/minisign"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/scrypt"
+)
+
+// NewOpenPGPKey generates an OpenPGP key and returns it along with its
+// armored public key.
+func NewOpenPGPKey(t *testing.T) (*openpgp.Entity, string) {
+	entity, err := openpgp.NewEntity("Test", "", "test@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	w, err := armor.Encode(buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = entity.Serialize(w)
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entity, buf.String()
+}
+
+// OpenPGPSign returns the armored detached signature of content.
+func OpenPGPSign(t *testing.T, signer *openpgp.Entity, content string) string {
+	buf := &bytes.Buffer{}
+	err := openpgp.ArmoredDetachSign(buf, signer, strings.NewReader(content), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+// NewMinisignKey generates a minisign key and returns it along with its
+// public key in the format of minisign public key files.
+func NewMinisignKey(t *testing.T) (minisign.PrivateKey, string) {
+	publicKey, privateKey, err := minisign.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := publicKey.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return privateKey, string(text) + "\n"
+}
+
+// MinisignSign returns the legacy minisign signature of content, with the
+// given trusted comment.
+func MinisignSign(t *testing.T, signer minisign.PrivateKey, content string, trustedComment string) string {
+	return string(minisign.SignWithComments(signer, []byte(content), trustedComment, "signature from minisign secret key"))
+}
+
+// MinisignSignPrehashed returns the pre-hashed minisign signature of
+// content, the default of recent minisign versions.
+func MinisignSignPrehashed(t *testing.T, signer minisign.PrivateKey, content string, trustedComment string) string {
+	r := minisign.NewReader(strings.NewReader(content))
+	_, err := io.Copy(io.Discard, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(r.SignWithComments(signer, trustedComment, "signature from minisign secret key"))
+}
+
+// NewSignifyKey generates a signify key and returns it along with its
+// public key in the format of signify public key files.
+func NewSignifyKey(t *testing.T) (ed25519.PrivateKey, string) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return privateKey, signifyEncode("signify public key", privateKey, publicKey)
+}
+
+// SignifySign returns the signify signature of content.
+func SignifySign(t *testing.T, signer ed25519.PrivateKey, content string) string {
+	return signifyEncode("verify with key.pub", signer, ed25519.Sign(signer, []byte(content)))
+}
+
+// signifyEncode encodes data in the signify file format, using the first
+// bytes of the public key of signer as key number.
+func signifyEncode(comment string, signer ed25519.PrivateKey, data []byte) string {
+	keyNum := signer.Public().(ed25519.PublicKey)[:8]
+	blob := append(append([]byte("Ed"), keyNum...), data...)
+	return "untrusted comment: " + comment + "\n" + base64.StdEncoding.EncodeToString(blob) + "\n"
+}
+
+// NewMinisignKeyFile generates a minisign private key file encrypted with
+// password and returns its path along with the public key. Unlike
+// minisign.EncryptKey, the key is encrypted with the lowest scrypt cost to
+// keep tests fast.
+func NewMinisignKeyFile(t *testing.T, password string) (string, string) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Key number, followed by the ed25519 private key.
+	plaintext := make([]byte, 8, 72)
+	_, err = rand.Read(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext = append(plaintext, privateKey...)
+	message := append([]byte("Ed"), plaintext...)
+	checksum := blake2b.Sum256(message)
+	salt := make([]byte, 32)
+	_, err = rand.Read(salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// minisign derives N=1024, r=8, p=1 from these ops and mem limits.
+	const ops, mem = 1 << 15, 1 << 24
+	keystream, err := scrypt.Key([]byte(password), salt, 1024, 8, 1, 104)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext := append(plaintext, checksum[:]...)
+	for i, k := range keystream {
+		ciphertext[i] ^= k
+	}
+	encoded := []byte("EdScB2")
+	encoded = append(encoded, salt...)
+	encoded = binary.LittleEndian.AppendUint64(encoded, ops)
+	encoded = binary.LittleEndian.AppendUint64(encoded, mem)
+	encoded = append(encoded, ciphertext...)
+	keyFile := TmpFile(t, "untrusted comment: minisign encrypted secret key\n"+base64.StdEncoding.EncodeToString(encoded)+"\n")
+
+	public := append([]byte("Ed"), plaintext[:8]...)
+	public = append(public, publicKey...)
+	return keyFile, "untrusted comment: minisign public key\n" + base64.StdEncoding.EncodeToString(public) + "\n"
+}
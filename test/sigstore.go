@@ -0,0 +1,138 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package test
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	protobundle "github.com/sigstore/protobuf-specs/gen/pb-go/bundle/v1"
+	protocommon "github.com/sigstore/protobuf-specs/gen/pb-go/common/v1"
+	protorekor "github.com/sigstore/protobuf-specs/gen/pb-go/rekor/v1"
+	"github.com/sigstore/sigstore-go/pkg/bundle"
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/sigstore/sigstore-go/pkg/testing/ca"
+	"github.com/sigstore/sigstore-go/pkg/tlog"
+)
+
+// NewSigstore returns a local Sigstore instance along with the path of a
+// trusted root file for its certificate authority and transparency log.
+func NewSigstore(t *testing.T) (*ca.VirtualSigstore, string) {
+	sigstore, err := ca.NewVirtualSigstore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The local instance identifies its logs by their hex encoded key id,
+	// whereas trusted root files hold the raw key id.
+	rekorLogs := sigstore.RekorLogs()
+	for _, rekorLog := range rekorLogs {
+		rekorLog.ID, err = hex.DecodeString(string(rekorLog.ID))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The certificates of the local instance have no signed certificate
+	// timestamps, so its certificate transparency logs are left out.
+	trustedRoot, err := root.NewTrustedRoot(root.TrustedRootMediaType01, sigstore.FulcioCertificateAuthorities(), nil, sigstore.TimestampingAuthorities(), rekorLogs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := trustedRoot.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sigstore, TmpFile(t, string(content))
+}
+
+// SigstoreSign returns the JSON Sigstore bundle of the signature of content
+// by identity, as authenticated by issuer.
+func SigstoreSign(t *testing.T, sigstore *ca.VirtualSigstore, identity string, issuer string, content string) string {
+	entity, err := sigstore.Sign(identity, issuer, []byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	verificationContent, err := entity.VerificationContent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signatureContent, err := entity.SignatureContent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	messageSignature := signatureContent.(*bundle.MessageSignature)
+	entries, err := entity.TlogEntries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pb := &protobundle.Bundle{
+		MediaType: "application/vnd.dev.sigstore.bundle.v0.3+json",
+		VerificationMaterial: &protobundle.VerificationMaterial{
+			Content: &protobundle.VerificationMaterial_Certificate{
+				Certificate: &protocommon.X509Certificate{RawBytes: verificationContent.Certificate().Raw},
+			},
+			TlogEntries: []*protorekor.TransparencyLogEntry{sigstoreTlogEntry(t, sigstore, entries[0])},
+		},
+		Content: &protobundle.Bundle_MessageSignature{
+			MessageSignature: &protocommon.MessageSignature{
+				MessageDigest: &protocommon.HashOutput{
+					Algorithm: protocommon.HashAlgorithm_SHA2_256,
+					Digest:    messageSignature.Digest(),
+				},
+				Signature: messageSignature.Signature(),
+			},
+		},
+	}
+	b, err := bundle.NewBundle(pb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := b.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(encoded)
+}
+
+// sigstoreTlogEntry completes a transparency log entry of the local
+// Sigstore instance with what bundles must hold: its kind, its signed
+// entry timestamp and its inclusion proof.
+func sigstoreTlogEntry(t *testing.T, sigstore *ca.VirtualSigstore, entry *tlog.Entry) *protorekor.TransparencyLogEntry {
+	tle := entry.TransparencyLogEntry()
+	var body struct {
+		APIVersion string `json:"apiVersion"`
+		Kind       string `json:"kind"`
+	}
+	err := json.Unmarshal(tle.CanonicalizedBody, &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tle.KindVersion = &protorekor.KindVersion{Kind: body.Kind, Version: body.APIVersion}
+	set, err := sigstore.RekorSignPayload(tlog.RekorPayload{
+		Body:           tle.CanonicalizedBody,
+		IntegratedTime: tle.IntegratedTime,
+		LogIndex:       tle.LogIndex,
+		LogID:          hex.EncodeToString(tle.LogId.KeyId),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tle.InclusionPromise = &protorekor.InclusionPromise{SignedEntryTimestamp: set}
+	proof, err := sigstore.GetInclusionProof(tle.CanonicalizedBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootHash, err := hex.DecodeString(*proof.RootHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tle.InclusionProof = &protorekor.InclusionProof{
+		LogIndex:   *proof.LogIndex,
+		RootHash:   rootHash,
+		TreeSize:   *proof.TreeSize,
+		Hashes:     [][]byte{},
+		Checkpoint: &protorekor.Checkpoint{Envelope: *proof.Checkpoint},
+	}
+	return tle
+}